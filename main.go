@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"github.com/curserio/tg-bot-gateway/tgbot"
+	"github.com/curserio/tg-bot-gateway/tgbot/middleware"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -45,6 +49,12 @@ func main() {
 		Poller:    &tgbot.LongPoller{Timeout: 10 * time.Second},
 		Verbose:   config.Verbose,
 		ParseMode: tgbot.ModeHTML,
+		RateLimit: &tgbot.RateLimit{
+			Burst:        5,
+			Refill:       time.Second,
+			CooldownText: "Too many requests, please slow down.",
+			Exempt:       config.Admins,
+		},
 	}
 
 	b, err := tgbot.NewBot(pref)
@@ -53,13 +63,43 @@ func main() {
 		return
 	}
 
+	b.Use(middleware.Recover())
+	if config.Verbose {
+		b.Use(middleware.Logger())
+	}
+
 	b.Handle(tgbot.OnText, func(c tgbot.Context) error {
 		return c.Reply("test")
 	})
 
+	started := time.Now()
+
+	admin := b.Group()
+	admin.Use(middleware.Whitelist(config.Admins...))
+
+	admin.Handle("/status", func(c tgbot.Context) error {
+		return c.Reply("uptime: " + time.Since(started).Round(time.Second).String())
+	})
+
+	for _, id := range config.Admins {
+		b.RegisterCommands(&tgbot.CommandScope{Type: tgbot.CommandScopeChat, ChatID: id}, "",
+			tgbot.Command{Text: "status", Description: "Show gateway status"})
+	}
+
 	log.Println("bot started. Ready to get messages")
 
-	b.Start()
+	go b.Start()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
 
-	defer b.Stop()
+	log.Println("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := b.Shutdown(ctx); err != nil {
+		log.Println(err)
+	}
 }
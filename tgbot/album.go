@@ -0,0 +1,115 @@
+package tgbot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// albumLimit is the maximum number of items in an album.
+const albumLimit = 10
+
+// albumBuffer collects the messages of albums until no more
+// items arrive for the delay, see OnAlbum.
+type albumBuffer struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	groups map[string]*albumGroup
+}
+
+type albumGroup struct {
+	updates []Update
+	timer   *time.Timer
+	done    func()
+	flushed bool
+}
+
+func newAlbumBuffer(delay time.Duration) *albumBuffer {
+	return &albumBuffer{
+		delay:  delay,
+		groups: make(map[string]*albumGroup),
+	}
+}
+
+// bufferAlbum adds the album item to its group. The group is fired
+// once it is complete or no more items arrive for the delay.
+func (b *Bot) bufferAlbum(u Update) {
+	a := b.albums
+	id := u.MediaGroupID
+
+	a.mu.Lock()
+	g, ok := a.groups[id]
+	if ok {
+		g.timer.Reset(a.delay)
+	} else {
+		g = &albumGroup{done: b.track()}
+		g.timer = time.AfterFunc(a.delay, func() { b.flushAlbum(id, g) })
+		a.groups[id] = g
+	}
+	g.updates = append(g.updates, u)
+	if len(g.updates) >= albumLimit {
+		// The album is complete. It is still flushed by the timer, as
+		// in the ordered dispatch modes this runs on the worker of the
+		// chat, which must not dispatch to its own queue.
+		delete(a.groups, id)
+		g.timer.Reset(0)
+	}
+	a.mu.Unlock()
+}
+
+// flushAlbum fires OnAlbum with the items of the group,
+// unless the group has been flushed already.
+func (b *Bot) flushAlbum(id string, g *albumGroup) {
+	a := b.albums
+
+	a.mu.Lock()
+	if g.flushed {
+		a.mu.Unlock()
+		return
+	}
+	g.flushed = true
+	if a.groups[id] == g {
+		delete(a.groups, id)
+	}
+	updates := g.updates
+	a.mu.Unlock()
+
+	defer g.done()
+
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].Message.MessageID < updates[j].Message.MessageID
+	})
+
+	album := make([]*Message, len(updates))
+	for i, u := range updates {
+		album[i] = b.NewContext(u).Message()
+	}
+
+	u := updates[0]
+	params := *u.AdditionalUpdateParams
+	params.Album = album
+	u.AdditionalUpdateParams = &params
+
+	b.dispatchUpdate(u, func(u Update, c Context) {
+		if !b.handle(OnAlbum, c) {
+			b.handle(OnUnhandled, c)
+		}
+	})
+}
+
+// handlesAlbums reports whether there is an OnAlbum handler,
+// so that the album items should be buffered.
+func (b *Bot) handlesAlbums() bool {
+	if _, ok := b.handlers[OnAlbum]; ok {
+		return true
+	}
+	if len(b.filtered[OnAlbum]) > 0 {
+		return true
+	}
+	for _, handlers := range b.stateHandlers {
+		if _, ok := handlers[OnAlbum]; ok {
+			return true
+		}
+	}
+	return false
+}
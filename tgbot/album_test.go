@@ -0,0 +1,55 @@
+package tgbot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// albumItem returns a photo message of the album.
+func albumItem(id int, album string) string {
+	return fmt.Sprintf(`{"message":{"message_id":%d,"date":0,"media_group_id":%q,`+
+		`"from":{"id":1,"first_name":"User"},"chat":{"id":1,"type":"private"},`+
+		`"photo":[{"file_id":"%d","width":1,"height":1}]}}`, id, album, id)
+}
+
+func TestAlbum(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{
+		Dispatch:   DispatchPerChat,
+		Workers:    1,
+		QueueSize:  1,
+		AlbumDelay: 50 * time.Millisecond,
+	})
+
+	albums := make(chan []*Message, 3)
+	b.Handle(OnAlbum, func(c Context) error {
+		albums <- c.Album()
+		return nil
+	})
+
+	// A complete album is fired without waiting for the delay,
+	// and the items after it start a new one.
+	for i := 12; i >= 1; i-- {
+		b.ProcessUpdate(decodeUpdate(t, albumItem(i, "a")))
+	}
+
+	var sizes []int
+	for len(sizes) < 2 {
+		select {
+		case album := <-albums:
+			sizes = append(sizes, len(album))
+			for i := 1; i < len(album); i++ {
+				if album[i-1].MessageID > album[i].MessageID {
+					t.Error("the album items are out of order")
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected 2 albums, got %v", sizes)
+		}
+	}
+	b.running.Wait()
+
+	if fmt.Sprint(sizes) != "[10 2]" {
+		t.Errorf("expected albums of 10 and 2 items, got %v", sizes)
+	}
+}
@@ -0,0 +1,473 @@
+package tgbot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ArgsKey is the context key the arguments parsed by
+// WithArgs are stored under, see Context.Get.
+const ArgsKey = "args"
+
+// WithArgs returns a handler which binds the command payload into
+// a new T before calling h. On a parse failure, it replies with
+// the error and a usage message generated from T instead.
+//
+// The fields of T are described by the struct tags:
+//
+//	arg:"name"        positional argument, in the order of fields;
+//	                  the last one may be a slice to collect the rest
+//	flag:"name"       --name value, --name=value, or --name for bools
+//	required:"true"   the argument must be given
+//	default:"value"   the value used when the argument is omitted
+//	enum:"a,b,c"      the list of allowed values
+//	help:"text"       the description shown in the usage message
+//
+// Supported field types are strings, booleans, integers, floats,
+// time.Duration and slices of them. Arguments are split by spaces,
+// and can be quoted with double or single quotes.
+//
+// Example:
+//
+//	type BanArgs struct {
+//		User   string        `arg:"user" required:"true" help:"username to ban"`
+//		For    time.Duration `flag:"for" default:"24h"`
+//		Reason []string      `arg:"reason"`
+//	}
+//
+//	b.Handle("/ban", tgbot.WithArgs(func(c tgbot.Context, args *BanArgs) error {
+//		...
+//	}))
+func WithArgs[T any](h func(Context, *T) error) HandlerFunc {
+	spec, err := argsSpecOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c Context) error {
+		args := new(T)
+
+		if err := spec.bind(reflect.ValueOf(args).Elem(), SplitArgs(c.Data())); err != nil {
+			// The error may quote the user input, so it is escaped
+			// for the default parse mode of the bot.
+			reply := fmt.Sprintf("%v\n\nUsage: %s", err, spec.usage(commandOf(c)))
+			return c.Reply(escapeText(c.Bot().parseMode, reply))
+		}
+
+		c.Set(ArgsKey, args)
+		return h(c, args)
+	}
+}
+
+// ParseArgs binds the arguments into dst, which must be a pointer
+// to a struct described by tags (see WithArgs).
+func ParseArgs(dst interface{}, args []string) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("tgbot: args destination must be a pointer to struct")
+	}
+
+	spec, err := argsSpecOf(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return spec.bind(v.Elem(), args)
+}
+
+// Usage returns the usage message of the command with arguments
+// described by the struct type of v (see WithArgs).
+func Usage(command string, v interface{}) string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	spec, err := argsSpecOf(t)
+	if err != nil {
+		return command
+	}
+	return spec.usage(command)
+}
+
+// SplitArgs splits s by spaces, respecting double and single quotes
+// and backslash escapes. Repeated spaces are treated as one.
+func SplitArgs(s string) []string {
+	var (
+		args   []string
+		arg    strings.Builder
+		inArg  bool
+		quote  rune
+		escape bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escape:
+			arg.WriteRune(r)
+			escape = false
+		case r == '\\' && quote != '\'':
+			escape, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// escapeText escapes s to be sent as plain text in the parse mode.
+func escapeText(mode ParseMode, s string) string {
+	switch mode {
+	case ModeHTML:
+		return html.EscapeString(s)
+	case ModeMarkdown:
+		return markdownEscaper.Replace(s)
+	case ModeMarkdownV2:
+		return markdownV2Escaper.Replace(s)
+	}
+	return s
+}
+
+var (
+	markdownEscaper   = escaper("_*`[")
+	markdownV2Escaper = escaper("\\_*[]()~`>#+-=|{}.!")
+)
+
+// escaper returns a replacer prefixing the chars with a backslash.
+func escaper(chars string) *strings.Replacer {
+	pairs := make([]string, 0, 2*len(chars))
+	for _, r := range chars {
+		pairs = append(pairs, string(r), "\\"+string(r))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// commandOf returns the command of the current message without the bot name.
+func commandOf(c Context) string {
+	if m := c.Message(); m != nil && m.Message != nil {
+		if match := cmdRx.FindStringSubmatch(m.Text); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+type argField struct {
+	name     string
+	flag     bool
+	index    int
+	required bool
+	def      string
+	hasDef   bool
+	enum     []string
+	help     string
+	typ      reflect.Type
+}
+
+type argsSpec struct {
+	positional []*argField
+	flags      map[string]*argField
+	fields     []*argField
+}
+
+var argsSpecs sync.Map
+
+func argsSpecOf(t reflect.Type) (*argsSpec, error) {
+	if spec, ok := argsSpecs.Load(t); ok {
+		return spec.(*argsSpec), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tgbot: args type %s is not a struct", t)
+	}
+
+	spec := &argsSpec{flags: make(map[string]*argField)}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		f := &argField{
+			index:    i,
+			typ:      sf.Type,
+			help:     sf.Tag.Get("help"),
+			required: sf.Tag.Get("required") == "true",
+		}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+		if enum := sf.Tag.Get("enum"); enum != "" {
+			f.enum = strings.Split(enum, ",")
+		}
+
+		if name, ok := sf.Tag.Lookup("arg"); ok {
+			f.name = name
+		} else if name, ok := sf.Tag.Lookup("flag"); ok {
+			f.name, f.flag = name, true
+		} else {
+			continue
+		}
+
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("tgbot: args field %s is unexported", sf.Name)
+		}
+		if !supportedArgType(sf.Type) {
+			return nil, fmt.Errorf("tgbot: args field %s has unsupported type %s", sf.Name, sf.Type)
+		}
+
+		if f.flag {
+			spec.flags[f.name] = f
+		} else {
+			if n := len(spec.positional); n > 0 && spec.positional[n-1].typ.Kind() == reflect.Slice {
+				return nil, fmt.Errorf("tgbot: args field %s follows a slice argument", sf.Name)
+			}
+			spec.positional = append(spec.positional, f)
+		}
+		spec.fields = append(spec.fields, f)
+	}
+
+	actual, _ := argsSpecs.LoadOrStore(t, spec)
+	return actual.(*argsSpec), nil
+}
+
+func supportedArgType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (s *argsSpec) bind(v reflect.Value, args []string) error {
+	set := make(map[*argField]bool)
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+
+		f, ok := s.flags[name]
+		if !ok {
+			return fmt.Errorf("unknown flag --%s", name)
+		}
+
+		if !hasValue {
+			if f.typ.Kind() == reflect.Bool {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+
+		if err := f.set(v.Field(f.index), value); err != nil {
+			return err
+		}
+		set[f] = true
+	}
+
+	for i, value := range positional {
+		if i >= len(s.positional) {
+			return fmt.Errorf("too many arguments")
+		}
+
+		f := s.positional[i]
+		if f.typ.Kind() == reflect.Slice {
+			for _, value := range positional[i:] {
+				if err := f.set(v.Field(f.index), value); err != nil {
+					return err
+				}
+			}
+			set[f] = true
+			break
+		}
+
+		if err := f.set(v.Field(f.index), value); err != nil {
+			return err
+		}
+		set[f] = true
+	}
+
+	for _, f := range s.fields {
+		if set[f] {
+			continue
+		}
+		if f.required {
+			return fmt.Errorf("%s is required", f.display())
+		}
+		if f.hasDef {
+			if err := f.set(v.Field(f.index), f.def); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *argField) display() string {
+	if f.flag {
+		return "--" + f.name
+	}
+	return strings.ToUpper(f.name)
+}
+
+func (f *argField) set(v reflect.Value, s string) error {
+	if len(f.enum) > 0 {
+		allowed := false
+		for _, e := range f.enum {
+			if s == e {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%s must be one of: %s", f.display(), strings.Join(f.enum, ", "))
+		}
+	}
+
+	if v.Kind() == reflect.Slice {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := f.setValue(elem, s); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+	}
+	return f.setValue(v, s)
+}
+
+func (f *argField) setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration like 1h30m", f.display())
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", f.display())
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", f.display())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s must be a non-negative integer", f.display())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s must be a number", f.display())
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+// usage returns a one-line synopsis of the command followed
+// by the descriptions of the arguments, if any.
+func (s *argsSpec) usage(command string) string {
+	synopsis := []string{command}
+	var help []string
+
+	for _, f := range s.fields {
+		var part string
+		if f.flag {
+			part = "--" + f.name
+			if f.typ.Kind() != reflect.Bool {
+				part += "=" + f.placeholder()
+			}
+		} else {
+			part = f.placeholder()
+			if f.typ.Kind() == reflect.Slice {
+				part += "..."
+			}
+		}
+
+		if !f.required {
+			part = "[" + part + "]"
+		}
+		synopsis = append(synopsis, part)
+
+		if f.help != "" || f.hasDef {
+			line := "  " + f.display()
+			if f.help != "" {
+				line += " - " + f.help
+			}
+			if f.hasDef {
+				line += " (default " + f.def + ")"
+			}
+			help = append(help, line)
+		}
+	}
+
+	usage := strings.TrimSpace(strings.Join(synopsis, " "))
+	if len(help) > 0 {
+		usage += "\n" + strings.Join(help, "\n")
+	}
+	return usage
+}
+
+func (f *argField) placeholder() string {
+	if len(f.enum) > 0 {
+		return strings.Join(f.enum, "|")
+	}
+	return strings.ToUpper(f.name)
+}
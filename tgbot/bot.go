@@ -1,9 +1,15 @@
 package tgbot
 
 import (
+	"context"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,39 +31,69 @@ func NewBot(pref Settings) (*Bot, error) {
 	if pref.OnError == nil {
 		pref.OnError = defaultOnError
 	}
+	if pref.States == nil {
+		pref.States = NewMemoryStateStorage()
+	}
+	if pref.AlbumDelay <= 0 {
+		pref.AlbumDelay = time.Second
+	}
+
+	if pref.URL != "" {
+		apiURL, err := url.Parse(pref.URL)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		withURL := *client
+		withURL.Transport = &apiTransport{url: apiURL, base: client.Transport}
+		client = &withURL
+	}
 
 	bot := &Bot{
 		Token:   pref.Token,
+		URL:     pref.URL,
 		Poller:  pref.Poller,
 		onError: pref.OnError,
 
 		Updates:  make(chan Update, pref.Updates),
 		handlers: make(map[string]HandlerFunc),
+		filtered: make(map[string][]filteredHandler),
 		stop:     make(chan chan struct{}),
 
+		states:        pref.States,
+		stateHandlers: make(map[State]map[string]HandlerFunc),
+		stateTimeouts: make(map[State]time.Duration),
+		albums:        newAlbumBuffer(pref.AlbumDelay),
+
 		synchronous: pref.Synchronous,
 		verbose:     pref.Verbose,
 		parseMode:   pref.ParseMode,
 		client:      client,
 	}
 
-	botApi, err := tgbotapi.NewBotAPI(bot.Token)
-	if err != nil {
-		return nil, err
-	}
-
-	botApi.Debug = pref.Verbose
-
-	bot.api = botApi
-
 	if pref.Offline {
-		bot.Me = &User{}
+		bot.api = &tgbotapi.BotAPI{
+			Token:  bot.Token,
+			Client: client,
+			Buffer: 100,
+		}
+		bot.Me = &User{&tgbotapi.User{}}
 	} else {
-		apiUser, err := bot.api.GetMe()
+		botApi, err := tgbotapi.NewBotAPIWithClient(bot.Token, client)
 		if err != nil {
 			return nil, err
 		}
-		bot.Me = &User{&apiUser}
+		bot.api = botApi
+		bot.Me = &User{&botApi.Self}
+	}
+
+	bot.api.Debug = pref.Verbose
+
+	if pref.RateLimit != nil {
+		bot.limiter = newLimiter(*pref.RateLimit)
+	}
+
+	if pref.Dispatch != DispatchConcurrent || pref.Workers > 0 {
+		bot.dispatcher = newDispatcher(bot, pref)
 	}
 
 	return bot, nil
@@ -74,12 +110,28 @@ type Bot struct {
 	onError func(error, Context)
 
 	handlers    map[string]HandlerFunc
+	filtered    map[string][]filteredHandler
+	patterns    []pattern
+	middleware  []MiddlewareFunc
+	commands    []commandSet
 	synchronous bool
+	dispatcher  *dispatcher
 	verbose     bool
 	parseMode   ParseMode
 	stop        chan chan struct{}
 	client      *http.Client
 	stopClient  chan struct{}
+
+	states        StateStorage
+	stateHandlers map[State]map[string]HandlerFunc
+	stateTimeouts map[State]time.Duration
+	albums        *albumBuffer
+	limiter       *limiter
+
+	mu       sync.Mutex
+	serial   sync.Mutex
+	running  sync.WaitGroup
+	inFlight int64
 }
 
 // Settings represents a utility struct for passing certain
@@ -97,6 +149,38 @@ type Settings struct {
 	// It makes ProcessUpdate return after the handler is finished.
 	Synchronous bool
 
+	// Dispatch defines the order in which handlers are run,
+	// see DispatchMode. It has no effect if Synchronous is set.
+	Dispatch DispatchMode
+
+	// Workers is the number of goroutines running handlers. In the
+	// DispatchConcurrent mode, zero starts a new goroutine for every
+	// update, otherwise it is defaulted to 8.
+	Workers int
+
+	// QueueSize is the capacity of the handler queue, defaulted to 100.
+	// In the ordered dispatch modes, every worker has a queue of its own.
+	QueueSize int
+
+	// Overflow defines what happens to an update when
+	// the handler queue is full, see OverflowPolicy.
+	Overflow OverflowPolicy
+
+	// States is the storage of conversation states,
+	// defaulted to MemoryStateStorage.
+	States StateStorage
+
+	// AlbumDelay is how long the next item of an album is waited
+	// for before OnAlbum fires, defaulted to 1 second. The album is
+	// fired after the delay even if more updates of the same chat
+	// arrive meanwhile, so in the ordered dispatch modes OnAlbum may
+	// run after the handlers of the later updates.
+	AlbumDelay time.Duration
+
+	// RateLimit, if set, drops the updates of users and chats
+	// sending them too fast, see RateLimit.
+	RateLimit *RateLimit
+
 	// Verbose forces bot to log all upcoming requests.
 	// Use for debugging purposes only.
 	Verbose bool
@@ -111,11 +195,37 @@ type Settings struct {
 	// Notice that context can be nil.
 	OnError func(error, Context)
 
-	// HTTP Client used to make requests to telegram api
+	// HTTP Client used to make requests to telegram api, defaulted to
+	// a client with a 1 minute timeout. The timeout of getUpdates
+	// requests is extended by LongPoller.Timeout.
 	Client *http.Client
 
 	// Offline allows to create a bot without network for testing purposes.
 	Offline bool
+
+	// URL of the Bot API server, defaulted to https://api.telegram.org.
+	// Use it for a local Bot API server or a fake one in tests.
+	URL string
+}
+
+// apiTransport redirects API requests to a custom Bot API server.
+type apiTransport struct {
+	url  *url.URL
+	base http.RoundTripper
+}
+
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.url.Scheme
+	req.URL.Host = t.url.Host
+	req.URL.Path = strings.TrimSuffix(t.url.Path, "/") + req.URL.Path
+	req.Host = t.url.Host
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
 }
 
 var (
@@ -123,10 +233,29 @@ var (
 	cbackRx = regexp.MustCompile(`^\f([-\w]+)(\|(.+))?$`)
 )
 
+// Use adds middleware to the global bot chain.
+//
+// Global middleware wraps only the handlers registered after
+// the call, so it is expected to be set up before any Handle.
+func (b *Bot) Use(middleware ...MiddlewareFunc) {
+	b.middleware = append(b.middleware, middleware...)
+}
+
 // Handle lets you set the handler for some command name or
 // one of the supported endpoints. It also applies middleware
 // if such passed to the function.
 //
+// Besides strings and callback endpoints, text messages can be
+// matched by *regexp.Regexp and Prefix, and callback data by
+// CallbackRegexp and CallbackPrefix. Exact commands and texts
+// (or callback uniques) take precedence over the patterns, which
+// in turn are tried in the order of registration before falling
+// back to OnText or OnCallback.
+//
+// Middleware is called in the order of registration: the global
+// middleware (see Use) goes first, followed by the middleware
+// passed to Handle, and the handler itself is called last.
+//
 // Example:
 //
 //	b.Handle("/start", func (c tele.Context) error {
@@ -140,21 +269,86 @@ var (
 // Middleware usage:
 //
 //	b.Handle("/ban", onBan, middleware.Whitelist(ids...))
-func (b *Bot) Handle(endpoint interface{}, h HandlerFunc) {
+//
+// Filters usage (see When):
+//
+//	b.Handle(tgbot.When("/ban", tgbot.IsAdmin()), onBan)
+func (b *Bot) Handle(endpoint interface{}, h HandlerFunc, m ...MiddlewareFunc) {
+	m = appendMiddleware(b.middleware, m)
+
 	handler := func(c Context) error {
-		return h(c)
+		return applyMiddleware(h, m...)(c)
+	}
+
+	var filters []Filter
+	if f, ok := endpoint.(Filtered); ok {
+		endpoint, filters = f.Endpoint, f.Filters
 	}
 
 	switch end := endpoint.(type) {
 	case string:
-		b.handlers[end] = handler
+		b.addHandler(end, handler, filters)
 	case CallbackEndpoint:
-		b.handlers[end.CallbackUnique()] = handler
+		b.addHandler(end.CallbackUnique(), handler, filters)
+	case *regexp.Regexp:
+		b.patterns = append(b.patterns, pattern{rx: end, handler: handler, filters: filters})
+	case Prefix:
+		b.patterns = append(b.patterns, pattern{prefix: string(end), handler: handler, filters: filters})
+	case CallbackRegexp:
+		b.patterns = append(b.patterns, pattern{callback: true, rx: end.Regexp, handler: handler, filters: filters})
+	case CallbackPrefix:
+		b.patterns = append(b.patterns, pattern{callback: true, prefix: string(end), handler: handler, filters: filters})
 	default:
 		panic("telebot: unsupported endpoint")
 	}
 }
 
+func (b *Bot) addHandler(end string, handler HandlerFunc, filters []Filter) {
+	if len(filters) == 0 {
+		b.handlers[end] = handler
+		return
+	}
+	b.filtered[end] = append(b.filtered[end], filteredHandler{filters: filters, handler: handler})
+}
+
+// AllowedUpdates returns the update types required by
+// the registered handlers, sorted and deduplicated.
+func (b *Bot) AllowedUpdates() []string {
+	seen := make(map[string]bool)
+	for end := range b.handlers {
+		for _, typ := range updateTypes(end) {
+			seen[typ] = true
+		}
+	}
+	for end := range b.filtered {
+		for _, typ := range updateTypes(end) {
+			seen[typ] = true
+		}
+	}
+	for _, handlers := range b.stateHandlers {
+		for end := range handlers {
+			for _, typ := range updateTypes(end) {
+				seen[typ] = true
+			}
+		}
+	}
+	for _, p := range b.patterns {
+		if p.callback {
+			seen[UpdateCallbackQuery] = true
+		} else {
+			seen[UpdateMessage] = true
+		}
+	}
+
+	allowed := make([]string, 0, len(seen))
+	for typ := range seen {
+		allowed = append(allowed, typ)
+	}
+	sort.Strings(allowed)
+
+	return allowed
+}
+
 // Start brings bot into motion by consuming incoming
 // updates (see Bot.Updates channel).
 func (b *Bot) Start() {
@@ -163,10 +357,17 @@ func (b *Bot) Start() {
 	}
 
 	// do nothing if called twice
+	b.mu.Lock()
 	if b.stopClient != nil {
+		b.mu.Unlock()
 		return
 	}
 	b.stopClient = make(chan struct{})
+	b.mu.Unlock()
+
+	if err := b.SyncCommands(); err != nil {
+		b.OnError(err, nil)
+	}
 
 	stop := make(chan struct{})
 	stopConfirm := make(chan struct{})
@@ -181,27 +382,91 @@ func (b *Bot) Start() {
 		// handle incoming updates
 		case upd := <-b.Updates:
 			b.ProcessUpdate(upd)
+			b.commit(upd)
 			// call to stop polling
 		case confirm := <-b.stop:
 			close(stop)
 			<-stopConfirm
 			close(confirm)
-			b.stopClient = nil
 			return
 		}
 	}
 }
 
-// Stop gracefully shuts the poller down.
+// commit notifies the poller that the update has been
+// handed over to ProcessUpdate.
+func (b *Bot) commit(u Update) {
+	if c, ok := b.Poller.(OffsetCommitter); ok {
+		c.Commit(u.UpdateID)
+	}
+}
+
+// Stop gracefully shuts the poller down. It does nothing
+// if the bot is not started. Running handlers and the updates
+// left in Bot.Updates are not waited for, see Shutdown.
 func (b *Bot) Stop() {
-	if b.stopClient != nil {
-		close(b.stopClient)
+	b.mu.Lock()
+	stopClient := b.stopClient
+	b.stopClient = nil
+	b.mu.Unlock()
+
+	if stopClient == nil {
+		return
 	}
+	close(stopClient)
+
 	confirm := make(chan struct{})
 	b.stop <- confirm
 	<-confirm
 }
 
+// Shutdown stops the poller, processes the updates left in
+// Bot.Updates and waits for running handlers to finish.
+//
+// If ctx expires first, Shutdown returns a *ShutdownError
+// reporting the number of abandoned handlers and carrying
+// the unprocessed updates, so that they can be persisted.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.Stop()
+
+	var abandoned []Update
+	for drained := false; !drained; {
+		select {
+		case upd := <-b.Updates:
+			if ctx.Err() != nil {
+				abandoned = append(abandoned, upd)
+				continue
+			}
+			b.ProcessUpdate(upd)
+			b.commit(upd)
+		default:
+			drained = true
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.running.Wait()
+		close(done)
+	}()
+
+	var handlers int
+	select {
+	case <-done:
+	case <-ctx.Done():
+		handlers = int(atomic.LoadInt64(&b.inFlight))
+	}
+
+	if handlers == 0 && len(abandoned) == 0 {
+		return nil
+	}
+	return &ShutdownError{
+		Err:      ctx.Err(),
+		Handlers: handlers,
+		Updates:  abandoned,
+	}
+}
+
 // NewContext returns a new native context object,
 // field by the passed update.
 func (b *Bot) NewContext(u Update) Context {
@@ -253,3 +518,34 @@ func (b *Bot) Reply(to *Message, what interface{}, opts ...interface{}) (*Messag
 	sendOpts.ReplyTo = to
 	return b.Send(&Chat{to.Chat}, what, sendOpts)
 }
+
+// Respond sends a response for a given callback query. A callback can
+// only be responded to once, subsequent attempts to respond to the same callback
+// will result in an error.
+//
+// Example:
+//
+//	b.Respond(c)
+//	b.Respond(c, response)
+func (b *Bot) Respond(c *Callback, resp ...*CallbackResponse) error {
+	if c == nil || c.CallbackQuery == nil {
+		return ErrBadCallback
+	}
+
+	r := &CallbackResponse{}
+	if len(resp) > 0 && resp[0] != nil {
+		r = resp[0]
+	}
+
+	_, err := b.api.AnswerCallbackQuery(tgbotapi.CallbackConfig{
+		CallbackQueryID: c.ID,
+		Text:            r.Text,
+		ShowAlert:       r.ShowAlert,
+		URL:             r.URL,
+		CacheTime:       r.CacheTime,
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package tgbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a Bot API server for the tests. It serves the queued
+// updates to getUpdates and answers any other method with the result
+// set for it or an empty one, recording the calls.
+type fakeAPI struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	updates []json.RawMessage
+	offsets []int
+	calls   map[string]int
+	results map[string]json.RawMessage
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{
+		calls:   make(map[string]int),
+		results: make(map[string]json.RawMessage),
+	}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// push queues the updates given as JSON objects without update_id,
+// which is assigned in order starting from 1.
+func (api *fakeAPI) push(updates ...string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	for _, u := range updates {
+		id := len(api.updates) + 1
+		raw := `{"update_id":` + strconv.Itoa(id) + `,` + u[1:]
+		api.updates = append(api.updates, json.RawMessage(raw))
+	}
+}
+
+// result sets the JSON result of the method.
+func (api *fakeAPI) result(method, data string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.results[method] = json.RawMessage(data)
+}
+
+func (api *fakeAPI) called(method string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.calls[method]
+}
+
+func (api *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+
+	api.mu.Lock()
+	api.calls[method]++
+	var result interface{} = struct{}{}
+	if data, ok := api.results[method]; ok {
+		result = data
+	}
+	api.mu.Unlock()
+
+	if method == "getUpdates" {
+		result = api.getUpdates(r)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":     true,
+		"result": result,
+	})
+}
+
+// getUpdates returns the updates starting from the offset,
+// like Telegram does, pausing a bit when there are none.
+func (api *fakeAPI) getUpdates(r *http.Request) []json.RawMessage {
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	api.mu.Lock()
+	api.offsets = append(api.offsets, offset)
+
+	start := offset - 1
+	if start < 0 {
+		start = 0
+	}
+	var updates []json.RawMessage
+	if start < len(api.updates) {
+		updates = api.updates[start:]
+	}
+	if len(updates) > limit {
+		updates = updates[:limit]
+	}
+	api.mu.Unlock()
+
+	if len(updates) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return append([]json.RawMessage{}, updates...)
+}
+
+// newTestBot returns an offline bot talking to the fake API,
+// failing the test on errors unless OnError is set.
+func newTestBot(t *testing.T, api *fakeAPI, pref Settings) *Bot {
+	pref.Offline = true
+	pref.URL = api.URL
+	if pref.OnError == nil {
+		pref.OnError = func(err error, c Context) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	b, err := NewBot(pref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// decodeUpdate decodes the update as if it were received from Telegram.
+func decodeUpdate(t *testing.T, data string) Update {
+	u, err := newUpdate([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// waitFor fails the test unless the condition
+// becomes true within a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// inlineCallback is a button press on a message sent in inline mode,
+// which has no message and chat.
+const inlineCallback = `{
+	"update_id": 1,
+	"callback_query": {
+		"id": "1",
+		"from": {"id": 42, "first_name": "User"},
+		"inline_message_id": "AAAA",
+		"chat_instance": "1",
+		"data": "vote"
+	}
+}`
+
+func TestContextInlineCallback(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{})
+	c := b.NewContext(decodeUpdate(t, inlineCallback))
+
+	if c.Message() != nil {
+		t.Error("expected no message")
+	}
+	if c.Chat() != nil {
+		t.Error("expected no chat")
+	}
+	if sender := c.Sender(); sender == nil || sender.ID != 42 {
+		t.Errorf("expected sender 42, got %v", sender)
+	}
+	if c.Text() != "" {
+		t.Errorf("expected no text, got %q", c.Text())
+	}
+}
+
+func TestLongPollTimeout(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{
+		Client: &http.Client{Timeout: time.Minute},
+	})
+
+	if api := b.pollAPI(0); api != b.api {
+		t.Error("expected the default API without a poll timeout")
+	}
+	if d := b.pollAPI(time.Minute).Client.Timeout; d != 2*time.Minute {
+		t.Errorf("expected the client timeout of 2m, got %v", d)
+	}
+	if d := b.api.Client.Timeout; d != time.Minute {
+		t.Errorf("expected the original client timeout to be kept, got %v", d)
+	}
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	api := newFakeAPI(t)
+	for i := 1; i <= 5; i++ {
+		api.push(textMessage(i, strconv.Itoa(i)))
+	}
+
+	store := &MemoryOffsetStore{}
+	b := newTestBot(t, api, Settings{Poller: &LongPoller{Store: store}})
+
+	var started, finished int32
+	b.Handle(OnText, func(c Context) error {
+		atomic.AddInt32(&started, 1)
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+		return nil
+	})
+
+	go b.Start()
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 5 })
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&finished); n != 5 {
+		t.Errorf("expected 5 finished handlers, got %d", n)
+	}
+	if id, _ := store.Load(); id != 5 {
+		t.Errorf("expected offset 5, got %d", id)
+	}
+}
+
+func TestShutdownDrainsUpdates(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{Synchronous: true})
+
+	var texts []string
+	b.Handle(OnText, func(c Context) error {
+		texts = append(texts, c.Text())
+		return nil
+	})
+
+	b.Updates <- decodeUpdate(t, textMessage(1, "1"))
+	b.Updates <- decodeUpdate(t, textMessage(1, "2"))
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(texts, ","); got != "1,2" {
+		t.Errorf("expected updates 1,2, got %s", got)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{})
+
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	b.Handle(OnText, func(c Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "1")))
+	<-started
+	b.Updates <- decodeUpdate(t, textMessage(1, "2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var shutdownErr *ShutdownError
+	if err := b.Shutdown(ctx); !errors.As(err, &shutdownErr) {
+		t.Fatalf("expected *ShutdownError, got %v", err)
+	}
+	if shutdownErr.Handlers != 1 || len(shutdownErr.Updates) != 1 {
+		t.Errorf("expected 1 abandoned handler and update, got %v", shutdownErr)
+	}
+	if !errors.Is(shutdownErr.Err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", shutdownErr.Err)
+	}
+}
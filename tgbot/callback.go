@@ -16,6 +16,29 @@ type Callback struct {
 	Unique string
 }
 
+// CallbackResponse builds a response to a Callback query.
+type CallbackResponse struct {
+	// Text for the notification. If not specified, nothing will be shown to the user.
+	Text string
+
+	// (Optional) If true, an alert will be shown by the client instead
+	// of a notification at the top of the chat screen. Defaults to false.
+	ShowAlert bool
+
+	// (Optional) URL that will be opened by the user's client.
+	// If you have created a Game and accepted the conditions via
+	// @BotFather, specify the URL that opens your game.
+	//
+	// Note: this will only work if the query comes from a game
+	// callback button. Otherwise, you may use deep-linking:
+	// https://telegram.me/your_bot?start=XXXX
+	URL string
+
+	// (Optional) The maximum amount of time in seconds that the result
+	// of the callback query may be cached client-side.
+	CacheTime int
+}
+
 // CallbackUnique returns ReplyButton.Text.
 func (t *ReplyButton) CallbackUnique() string {
 	return t.Text
@@ -1,6 +1,17 @@
 package tgbot
 
-import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"time"
+)
+
+// These are the types of chats, see ChatType.
+const (
+	ChatPrivate    = "private"
+	ChatGroup      = "group"
+	ChatSuperGroup = "supergroup"
+	ChatChannel    = "channel"
+)
 
 type User struct {
 	*tgbotapi.User
@@ -17,3 +28,92 @@ type Chat struct {
 func (c *Chat) ChatID() int {
 	return int(c.ID)
 }
+
+// ChatMemberUpdate object represents changes in the status of a chat member.
+type ChatMemberUpdate struct {
+	// Chat where the user belongs to.
+	Chat *tgbotapi.Chat `json:"chat"`
+
+	// Sender which user the action was triggered.
+	Sender *tgbotapi.User `json:"from"`
+
+	// Unixtime, use ChatMemberUpdate.Time() to get time.Time.
+	Unixtime int64 `json:"date"`
+
+	// Previous information about the chat member.
+	OldChatMember *tgbotapi.ChatMember `json:"old_chat_member"`
+
+	// New information about the chat member.
+	NewChatMember *tgbotapi.ChatMember `json:"new_chat_member"`
+
+	// (Optional) InviteLink which was used by the user to
+	// join the chat; for joining by invite link events only.
+	InviteLink *ChatInviteLink `json:"invite_link"`
+}
+
+// Time returns the moment of the change in local time.
+func (c *ChatMemberUpdate) Time() time.Time {
+	return time.Unix(c.Unixtime, 0)
+}
+
+// ChatJoinRequest represents a join request sent to a chat.
+type ChatJoinRequest struct {
+	// Chat to which the request was sent.
+	Chat *tgbotapi.Chat `json:"chat"`
+
+	// Sender is the user that sent the join request.
+	Sender *tgbotapi.User `json:"from"`
+
+	// Unixtime, use ChatJoinRequest.Time() to get time.Time.
+	Unixtime int64 `json:"date"`
+
+	// (Optional) Bio of the user.
+	Bio string `json:"bio"`
+
+	// (Optional) InviteLink that was used by the user to send the join request.
+	InviteLink *ChatInviteLink `json:"invite_link"`
+}
+
+// Time returns the moment of the request in local time.
+func (r *ChatJoinRequest) Time() time.Time {
+	return time.Unix(r.Unixtime, 0)
+}
+
+// ChatInviteLink object represents an invite for a chat.
+type ChatInviteLink struct {
+	// The invite link.
+	InviteLink string `json:"invite_link"`
+
+	// Invite link name.
+	Name string `json:"name"`
+
+	// The creator of the link.
+	Creator *tgbotapi.User `json:"creator"`
+
+	// If the link is primary.
+	IsPrimary bool `json:"is_primary"`
+
+	// If the link is revoked.
+	IsRevoked bool `json:"is_revoked"`
+
+	// (Optional) Point in time when the link will expire,
+	// use ChatInviteLink.ExpireDate() to get time.Time.
+	ExpireUnixtime int64 `json:"expire_date,omitempty"`
+
+	// (Optional) Maximum number of users that can be members of
+	// the chat simultaneously.
+	MemberLimit int `json:"member_limit,omitempty"`
+
+	// True, if users joining the chat via the link need to
+	// be approved by chat administrators.
+	JoinRequest bool `json:"creates_join_request"`
+
+	// (Optional) Number of pending join requests
+	// created using this link.
+	PendingCount int `json:"pending_join_request_count"`
+}
+
+// ExpireDate returns the moment of the link expiration in local time.
+func (c *ChatInviteLink) ExpireDate() time.Time {
+	return time.Unix(c.ExpireUnixtime, 0)
+}
@@ -0,0 +1,157 @@
+package tgbot
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Command represents a bot command shown in the Telegram menu.
+type Command struct {
+	// Text is a text of the command, 1-32 characters.
+	// Can contain only lowercase English letters, digits and underscores.
+	Text string `json:"command"`
+
+	// Description of the command, 3-256 characters.
+	Description string `json:"description"`
+}
+
+// CommandScope object represents a scope to which bot commands are applied.
+type CommandScope struct {
+	Type   string `json:"type"`
+	ChatID int64  `json:"chat_id,omitempty"`
+	UserID int64  `json:"user_id,omitempty"`
+}
+
+// CommandScope types
+const (
+	CommandScopeDefault         = "default"
+	CommandScopeAllPrivateChats = "all_private_chats"
+	CommandScopeAllGroupChats   = "all_group_chats"
+	CommandScopeAllChatAdmin    = "all_chat_administrators"
+	CommandScopeChat            = "chat"
+	CommandScopeChatAdmin       = "chat_administrators"
+	CommandScopeChatMember      = "chat_member"
+)
+
+// commandSet is a list of commands registered
+// for a particular scope and language.
+type commandSet struct {
+	scope    *CommandScope
+	lang     string
+	commands []Command
+}
+
+// RegisterCommands adds the commands to the menu of the given scope and
+// language. A nil scope stands for the default one, and an empty language
+// for all the languages without dedicated commands.
+//
+// Registered commands are pushed to Telegram when the bot starts (see
+// SyncCommands). Registering no commands for the scope and language
+// deletes the menu previously set for them.
+func (b *Bot) RegisterCommands(scope *CommandScope, lang string, commands ...Command) {
+	for i := range b.commands {
+		set := &b.commands[i]
+		if set.lang == lang && sameScope(set.scope, scope) {
+			set.commands = append(set.commands, commands...)
+			return
+		}
+	}
+
+	b.commands = append(b.commands, commandSet{
+		scope:    scope,
+		lang:     lang,
+		commands: commands,
+	})
+}
+
+// SyncCommands pushes the registered commands to Telegram,
+// calling setMyCommands or deleteMyCommands for every scope
+// and language they have been registered for.
+func (b *Bot) SyncCommands() error {
+	for _, set := range b.commands {
+		var err error
+		if len(set.commands) == 0 {
+			err = b.DeleteCommands(set.scope, set.lang)
+		} else {
+			err = b.SetCommands(set.commands, set.scope, set.lang)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCommands changes the list of the bot's commands for
+// the given scope and language.
+func (b *Bot) SetCommands(commands []Command, scope *CommandScope, lang string) error {
+	v, err := commandParams(scope, lang)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(commands)
+	if err != nil {
+		return wrapError(err)
+	}
+	v.Set("commands", string(data))
+
+	_, err = b.request("setMyCommands", v)
+	return err
+}
+
+// DeleteCommands deletes the list of the bot's commands for
+// the given scope and language.
+func (b *Bot) DeleteCommands(scope *CommandScope, lang string) error {
+	v, err := commandParams(scope, lang)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.request("deleteMyCommands", v)
+	return err
+}
+
+// Commands returns the current list of the bot's commands for
+// the given scope and language.
+func (b *Bot) Commands(scope *CommandScope, lang string) ([]Command, error) {
+	v, err := commandParams(scope, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.request("getMyCommands", v)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []Command
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, wrapError(err)
+	}
+	return commands, nil
+}
+
+func commandParams(scope *CommandScope, lang string) (url.Values, error) {
+	v := url.Values{}
+
+	if scope != nil {
+		data, err := json.Marshal(scope)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		v.Set("scope", string(data))
+	}
+	if lang != "" {
+		v.Set("language_code", lang)
+	}
+
+	return v, nil
+}
+
+func sameScope(a, b *CommandScope) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
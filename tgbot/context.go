@@ -3,6 +3,7 @@ package tgbot
 import (
 	"strings"
 	"sync"
+	"time"
 )
 
 // HandlerFunc represents a handler function, which is
@@ -22,32 +23,38 @@ type Context interface {
 	// Callback returns stored callback if such presented.
 	Callback() *Callback
 
-	//// Query returns stored query if such presented.
-	//Query() *Query
-	//
-	//// InlineResult returns stored inline result if such presented.
-	//InlineResult() *InlineResult
-	//
+	// Album returns all the messages of the album for OnAlbum,
+	// the first one of them is also returned by Message.
+	Album() []*Message
+
+	// Query returns stored query if such presented.
+	Query() *Query
+
+	// InlineResult returns stored inline result if such presented.
+	InlineResult() *InlineResult
+
 	//// ShippingQuery returns stored shipping query if such presented.
 	//ShippingQuery() *ShippingQuery
 	//
 	//// PreCheckoutQuery returns stored pre checkout query if such presented.
 	//PreCheckoutQuery() *PreCheckoutQuery
-	//
-	//// Poll returns stored poll if such presented.
-	//Poll() *Poll
-	//
-	//// PollAnswer returns stored poll answer if such presented.
-	//PollAnswer() *PollAnswer
-	//
-	//// ChatMember returns chat member changes.
-	//ChatMember() *ChatMemberUpdate
-	//
-	//// ChatJoinRequest returns cha
-	//ChatJoinRequest() *ChatJoinRequest
-	//
-	//// Migration returns both migration from and to chat IDs.
-	//Migration() (int64, int64)
+
+	// Poll returns stored poll if such presented.
+	Poll() *Poll
+
+	// PollAnswer returns stored poll answer if such presented.
+	PollAnswer() *PollAnswer
+
+	// ChatMember returns chat member changes, either of the bot
+	// itself or of another member.
+	ChatMember() *ChatMemberUpdate
+
+	// ChatJoinRequest returns the join request sent to the chat.
+	ChatJoinRequest() *ChatJoinRequest
+
+	// Migration returns both migration from and to chat IDs.
+	// Returns zeros if the message is not a migration one.
+	Migration() (int64, int64)
 
 	// Sender returns the current recipient, depending on the context type.
 	// Returns nil if user is not presented.
@@ -77,8 +84,18 @@ type Context interface {
 	// In the case when no related data presented, returns an empty string.
 	Data() string
 
+	// Matches returns the submatches of the regexp endpoint which handled
+	// the update, the whole match first. For prefix endpoints, it returns
+	// the whole text followed by the text without the prefix.
+	Matches() []string
+
+	// Match returns the submatch of the named group (?P<name>...)
+	// of the regexp endpoint which handled the update.
+	Match(name string) string
+
 	// Args returns a raw slice of command or callback arguments as strings.
-	// The message arguments split by space, while the callback's ones by a "|" symbol.
+	// The message arguments split by spaces, respecting quotes (see SplitArgs),
+	// while the callback's ones by a "|" symbol.
 	Args() []string
 
 	// Send sends a message to the current recipient.
@@ -133,14 +150,31 @@ type Context interface {
 	//// Accept finalizes the current deal.
 	//// See Accept from bot.go.
 	//Accept(errorMessage ...string) error
-	//
-	//// Answer sends a response to the current inline query.
-	//// See Answer from bot.go.
-	//Answer(resp *QueryResponse) error
-	//
-	//// Respond sends a response for the current callback query.
-	//// See Respond from bot.go.
-	//Respond(resp ...*CallbackResponse) error
+
+	// Answer sends a response to the current inline query.
+	// See Answer from inline.go.
+	Answer(resp *QueryResponse) error
+
+	// Respond sends a response for the current callback query.
+	// See Respond from bot.go.
+	Respond(resp ...*CallbackResponse) error
+
+	// State returns the current conversation state of the sender
+	// in the current chat, see Bot.HandleState.
+	State() State
+
+	// SetState transitions the conversation to the given state,
+	// keeping its data. See also Bot.SetStateTimeout.
+	SetState(state State) error
+
+	// ResetState finishes the conversation, removing its state and data.
+	ResetState() error
+
+	// StateData retrieves data bound to the conversation.
+	StateData(key string) interface{}
+
+	// SetStateData saves data bound to the conversation.
+	SetStateData(key string, val interface{}) error
 
 	// Get retrieves data from the context.
 	Get(key string) interface{}
@@ -154,6 +188,9 @@ type tgContext struct {
 	u     Update
 	lock  sync.RWMutex
 	store map[string]interface{}
+
+	stateLoaded bool
+	state       StateRecord
 }
 
 func (c *tgContext) Bot() *Bot {
@@ -167,8 +204,18 @@ func (c *tgContext) Update() Update {
 func (c *tgContext) Message() *Message {
 	switch {
 	case c.u.Message != nil:
-		return &Message{Message: c.u.Message}
+		m := &Message{Message: c.u.Message}
+		if c.u.AdditionalUpdateParams != nil {
+			m.Via = c.u.ViaBot
+			m.AlbumID = c.u.MediaGroupID
+		}
+		return m
 	case c.u.CallbackQuery != nil:
+		// Callbacks of inline mode messages carry
+		// only the InlineMessageID.
+		if c.u.CallbackQuery.Message == nil {
+			return nil
+		}
 		return &Message{Message: c.u.CallbackQuery.Message}
 	case c.u.EditedMessage != nil:
 		return &Message{Message: c.u.EditedMessage}
@@ -185,13 +232,84 @@ func (c *tgContext) Message() *Message {
 }
 
 func (c *tgContext) Callback() *Callback {
+	if c.u.CallbackQuery == nil {
+		return nil
+	}
 	return &Callback{CallbackQuery: c.u.CallbackQuery}
 }
 
+func (c *tgContext) Album() []*Message {
+	if c.u.AdditionalUpdateParams == nil {
+		return nil
+	}
+	return c.u.Album
+}
+
+func (c *tgContext) Query() *Query {
+	if c.u.InlineQuery == nil {
+		return nil
+	}
+	return &Query{InlineQuery: c.u.InlineQuery}
+}
+
+func (c *tgContext) InlineResult() *InlineResult {
+	if c.u.ChosenInlineResult == nil {
+		return nil
+	}
+	return &InlineResult{ChosenInlineResult: c.u.ChosenInlineResult}
+}
+
+func (c *tgContext) Poll() *Poll {
+	return c.u.Poll
+}
+
+func (c *tgContext) PollAnswer() *PollAnswer {
+	return c.u.PollAnswer
+}
+
+func (c *tgContext) ChatMember() *ChatMemberUpdate {
+	switch {
+	case c.u.ChatMember != nil:
+		return c.u.ChatMember
+	case c.u.MyChatMember != nil:
+		return c.u.MyChatMember
+	default:
+		return nil
+	}
+}
+
+func (c *tgContext) ChatJoinRequest() *ChatJoinRequest {
+	return c.u.ChatJoinRequest
+}
+
+func (c *tgContext) Migration() (int64, int64) {
+	m := c.Message()
+	switch {
+	case m == nil || m.Message == nil || m.Chat == nil:
+		return 0, 0
+	case m.MigrateToChatID != 0:
+		return m.Chat.ID, m.MigrateToChatID
+	case m.MigrateFromChatID != 0:
+		return m.MigrateFromChatID, m.Chat.ID
+	default:
+		return 0, 0
+	}
+}
+
 func (c *tgContext) Sender() *User {
 	switch {
 	case c.u.CallbackQuery != nil:
 		return &User{c.u.CallbackQuery.From}
+	case c.u.InlineQuery != nil:
+		return &User{c.u.InlineQuery.From}
+	case c.u.ChosenInlineResult != nil:
+		return &User{c.u.ChosenInlineResult.From}
+	case c.u.PollAnswer != nil:
+		return &User{c.u.PollAnswer.Sender}
+	case c.ChatMember() != nil:
+		return &User{c.ChatMember().Sender}
+	case c.u.ChatJoinRequest != nil:
+		return &User{c.u.ChatJoinRequest.Sender}
 	case c.Message() != nil:
 		return &User{c.Message().From}
 	default:
@@ -203,6 +321,10 @@ func (c *tgContext) Chat() *Chat {
 	switch {
 	case c.Message() != nil:
 		return &Chat{c.Message().Chat}
+	case c.ChatMember() != nil:
+		return &Chat{c.ChatMember().Chat}
+	case c.u.ChatJoinRequest != nil:
+		return &Chat{c.u.ChatJoinRequest.Chat}
 	default:
 		return nil
 	}
@@ -233,6 +355,10 @@ func (c *tgContext) Data() string {
 		return c.u.Payload
 	case c.u.CallbackQuery != nil:
 		return c.u.Payload
+	case c.u.InlineQuery != nil:
+		return c.u.InlineQuery.Query
+	case c.u.ChosenInlineResult != nil:
+		return c.u.ChosenInlineResult.Query
 	default:
 		return ""
 	}
@@ -241,16 +367,32 @@ func (c *tgContext) Data() string {
 func (c *tgContext) Args() []string {
 	switch {
 	case c.u.Message != nil:
-		payload := strings.Trim(c.u.Payload, " ")
-		if payload != "" {
-			return strings.Split(payload, " ")
-		}
+		return SplitArgs(c.u.Payload)
 	case c.u.CallbackQuery != nil:
 		return strings.Split(c.u.CallbackQuery.Data, "|")
 	}
 	return nil
 }
 
+func (c *tgContext) Matches() []string {
+	if c.u.AdditionalUpdateParams == nil {
+		return nil
+	}
+	return c.u.Matches
+}
+
+func (c *tgContext) Match(name string) string {
+	if c.u.AdditionalUpdateParams == nil {
+		return ""
+	}
+	for i, n := range c.u.matchNames {
+		if n == name && name != "" && i < len(c.u.Matches) {
+			return c.u.Matches[i]
+		}
+	}
+	return ""
+}
+
 func (c *tgContext) Send(what interface{}, opts ...interface{}) error {
 	_, err := c.b.Send(c.Recipient(), what, opts...)
 	return err
@@ -265,6 +407,20 @@ func (c *tgContext) Reply(what interface{}, opts ...interface{}) error {
 	return err
 }
 
+func (c *tgContext) Respond(resp ...*CallbackResponse) error {
+	if c.u.CallbackQuery == nil {
+		return ErrBadCallback
+	}
+	return c.b.Respond(c.Callback(), resp...)
+}
+
+func (c *tgContext) Answer(resp *QueryResponse) error {
+	if c.u.InlineQuery == nil {
+		return ErrBadQuery
+	}
+	return c.b.Answer(c.Query(), resp)
+}
+
 func (c *tgContext) Get(key string) interface{} {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -280,3 +436,138 @@ func (c *tgContext) Set(key string, val interface{}) {
 	}
 	c.store[key] = val
 }
+
+func (c *tgContext) stateKey() (StateKey, bool) {
+	var key StateKey
+	if chat := c.Chat(); chat != nil && chat.Chat != nil {
+		key.ChatID = chat.ID
+	}
+	if sender := c.Sender(); sender != nil && sender.User != nil {
+		key.UserID = int64(sender.ID)
+	}
+	return key, key != StateKey{}
+}
+
+// loadState returns the conversation record, loading it once per context.
+// The caller must hold the lock.
+func (c *tgContext) loadState() (StateRecord, error) {
+	if c.stateLoaded {
+		return c.state, nil
+	}
+
+	key, ok := c.stateKey()
+	if !ok {
+		return StateRecord{}, ErrNoStateKey
+	}
+
+	rec, err := c.b.states.Get(key)
+	if err != nil {
+		return StateRecord{}, err
+	}
+	if rec.Expired() {
+		if err := c.b.states.Delete(key); err != nil {
+			return StateRecord{}, err
+		}
+		rec = StateRecord{}
+	}
+
+	c.state, c.stateLoaded = rec, true
+	return rec, nil
+}
+
+// saveState stores the conversation record. The caller must hold the lock.
+func (c *tgContext) saveState(rec StateRecord) error {
+	key, ok := c.stateKey()
+	if !ok {
+		return ErrNoStateKey
+	}
+
+	if err := c.b.states.Set(key, rec); err != nil {
+		return err
+	}
+
+	c.state, c.stateLoaded = rec, true
+	return nil
+}
+
+func (c *tgContext) State() State {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rec, err := c.loadState()
+	if err != nil {
+		if err != ErrNoStateKey {
+			c.b.OnError(err, c)
+		}
+		return ""
+	}
+	return rec.State
+}
+
+func (c *tgContext) SetState(state State) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rec, err := c.loadState()
+	if err != nil {
+		return err
+	}
+
+	rec.State = state
+	rec.Expires = time.Time{}
+	if d, ok := c.b.stateTimeouts[state]; ok {
+		rec.Expires = time.Now().Add(d)
+	}
+
+	return c.saveState(rec)
+}
+
+func (c *tgContext) ResetState() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key, ok := c.stateKey()
+	if !ok {
+		return ErrNoStateKey
+	}
+
+	if err := c.b.states.Delete(key); err != nil {
+		return err
+	}
+
+	c.state, c.stateLoaded = StateRecord{}, true
+	return nil
+}
+
+func (c *tgContext) StateData(key string) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rec, err := c.loadState()
+	if err != nil {
+		if err != ErrNoStateKey {
+			c.b.OnError(err, c)
+		}
+		return nil
+	}
+	return rec.Data[key]
+}
+
+func (c *tgContext) SetStateData(key string, val interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rec, err := c.loadState()
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]interface{}, len(rec.Data)+1)
+	for k, v := range rec.Data {
+		data[k] = v
+	}
+	data[key] = val
+	rec.Data = data
+
+	return c.saveState(rec)
+}
@@ -0,0 +1,203 @@
+package tgbot
+
+import "sync/atomic"
+
+// DispatchMode defines how the handlers of different
+// updates are run relative to each other.
+type DispatchMode int
+
+const (
+	// DispatchConcurrent runs handlers in parallel, so no order
+	// is guaranteed. This is the default mode.
+	DispatchConcurrent DispatchMode = iota
+
+	// DispatchPerChat runs the handlers of one chat strictly in the
+	// order of updates, while different chats are handled in parallel.
+	DispatchPerChat
+
+	// DispatchPerUser is like DispatchPerChat, but keeps the order
+	// of updates sent by one user across all the chats.
+	DispatchPerUser
+)
+
+// OverflowPolicy defines what happens to an update
+// when the handler queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks ProcessUpdate, and so the poller,
+	// until there is room in the queue. This is the default policy.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest queued update
+	// to make room for the new one.
+	OverflowDropOldest
+
+	// OverflowReject drops the new update, reporting
+	// ErrQueueFull to the OnError callback.
+	OverflowReject
+)
+
+// QueueStats is a snapshot of the handler queue counters.
+type QueueStats struct {
+	// Depth is the number of updates waiting in the queues.
+	Depth int
+
+	// Dropped is the number of updates dropped by OverflowDropOldest.
+	Dropped uint64
+
+	// Rejected is the number of updates rejected by OverflowReject.
+	Rejected uint64
+}
+
+// QueueStats returns the counters of the handler queue.
+// They are always zero unless a worker pool is configured
+// (see Settings.Workers and Settings.Dispatch).
+func (b *Bot) QueueStats() QueueStats {
+	if b.dispatcher == nil {
+		return QueueStats{}
+	}
+	return b.dispatcher.stats()
+}
+
+// task is a handler call waiting in the queue.
+type task struct {
+	c   Context
+	run func()
+
+	// cancel releases the task if it is never run.
+	cancel func()
+}
+
+// dispatcher runs handlers on a fixed number of workers. In the ordered
+// modes, every worker has its own queue, and updates with the same key
+// always go to the same worker, which keeps their order. Otherwise all
+// the workers share one queue.
+type dispatcher struct {
+	b        *Bot
+	mode     DispatchMode
+	overflow OverflowPolicy
+	queues   []chan *task
+
+	dropped  uint64
+	rejected uint64
+}
+
+func newDispatcher(b *Bot, pref Settings) *dispatcher {
+	workers, queueSize := pref.Workers, pref.QueueSize
+	if workers <= 0 {
+		workers = 8
+	}
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	d := &dispatcher{
+		b:        b,
+		mode:     pref.Dispatch,
+		overflow: pref.Overflow,
+	}
+
+	if d.mode == DispatchConcurrent {
+		d.queues = []chan *task{make(chan *task, queueSize)}
+	} else {
+		d.queues = make([]chan *task, workers)
+		for i := range d.queues {
+			d.queues[i] = make(chan *task, queueSize)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		q := d.queues[i%len(d.queues)]
+
+		go func() {
+			for t := range q {
+				t.run()
+			}
+		}()
+	}
+
+	return d
+}
+
+// dispatch enqueues the task to the worker of the context key,
+// following the overflow policy if the queue is full.
+func (d *dispatcher) dispatch(t *task) {
+	q := d.queue(t.c)
+
+	switch d.overflow {
+	case OverflowReject:
+		select {
+		case q <- t:
+		default:
+			atomic.AddUint64(&d.rejected, 1)
+			t.cancel()
+			d.b.OnError(ErrQueueFull, t.c)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q <- t:
+				return
+			default:
+			}
+
+			select {
+			case old := <-q:
+				atomic.AddUint64(&d.dropped, 1)
+				old.cancel()
+			default:
+			}
+		}
+	default:
+		q <- t
+	}
+}
+
+// ordered reports whether the updates are dispatched by their key.
+func (d *dispatcher) ordered() bool {
+	return d.mode != DispatchConcurrent
+}
+
+func (d *dispatcher) queue(c Context) chan *task {
+	if len(d.queues) == 1 {
+		return d.queues[0]
+	}
+
+	key := uint64(d.key(c))
+	return d.queues[key%uint64(len(d.queues))]
+}
+
+// key returns the chat or the user ID of the context, depending
+// on the mode, falling back to the other one if it is missing,
+// e.g. callbacks of inline mode messages are keyed by the sender.
+func (d *dispatcher) key(c Context) int64 {
+	var chatID, userID int64
+	if chat := c.Chat(); chat != nil && chat.Chat != nil {
+		chatID = chat.ID
+	}
+	if sender := c.Sender(); sender != nil && sender.User != nil {
+		userID = int64(sender.ID)
+	}
+
+	if d.mode == DispatchPerUser && userID != 0 {
+		return userID
+	}
+	if chatID != 0 {
+		return chatID
+	}
+	return userID
+}
+
+func (d *dispatcher) stats() QueueStats {
+	var depth int
+	for _, q := range d.queues {
+		depth += len(q)
+	}
+
+	return QueueStats{
+		Depth:    depth,
+		Dropped:  atomic.LoadUint64(&d.dropped),
+		Rejected: atomic.LoadUint64(&d.rejected),
+	}
+}
@@ -0,0 +1,157 @@
+package tgbot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchInlineCallback(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{Dispatch: DispatchPerChat})
+
+	handled := make(chan struct{})
+	b.Handle(OnCallback, func(c Context) error {
+		close(handled)
+		return nil
+	})
+
+	u := decodeUpdate(t, inlineCallback)
+	if key := b.dispatcher.key(b.NewContext(u)); key != 42 {
+		t.Errorf("expected the sender key 42, got %d", key)
+	}
+
+	b.ProcessUpdate(u)
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("the callback is not handled")
+	}
+}
+
+func TestDispatchPerChatOrder(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{
+		Dispatch: DispatchPerChat,
+		Workers:  4,
+	})
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64][]int)
+	)
+	b.Handle(OnText, func(c Context) error {
+		n, _ := strconv.Atoi(c.Text())
+
+		// The earlier updates of a chat take longer,
+		// which would reorder them if run in parallel.
+		time.Sleep(time.Duration(20-n) * 100 * time.Microsecond)
+
+		mu.Lock()
+		seen[c.Chat().ID] = append(seen[c.Chat().ID], n)
+		mu.Unlock()
+		return nil
+	})
+
+	const chats, updates = 5, 20
+	for i := 0; i < updates; i++ {
+		for chat := 1; chat <= chats; chat++ {
+			b.ProcessUpdate(decodeUpdate(t, textMessage(chat, strconv.Itoa(i))))
+		}
+	}
+	b.running.Wait()
+
+	for chat := int64(1); chat <= chats; chat++ {
+		got := seen[chat]
+		if len(got) != updates {
+			t.Fatalf("chat %d: expected %d updates, got %v", chat, updates, got)
+		}
+		for i, n := range got {
+			if n != i {
+				t.Fatalf("chat %d: updates are out of order: %v", chat, got)
+			}
+		}
+	}
+}
+
+// blockedBot returns a bot with a single worker queue of one update,
+// whose handler blocks until release is closed. It returns once the
+// first update has been taken by the worker.
+func blockedBot(t *testing.T, pref Settings) (b *Bot, handled func() []string, release chan struct{}) {
+	pref.Dispatch = DispatchPerChat
+	pref.Workers = 1
+	pref.QueueSize = 1
+	b = newTestBot(t, newFakeAPI(t), pref)
+
+	var (
+		mu      sync.Mutex
+		texts   []string
+		started = make(chan struct{}, 10)
+	)
+	release = make(chan struct{})
+
+	b.Handle(OnText, func(c Context) error {
+		started <- struct{}{}
+		<-release
+
+		mu.Lock()
+		texts = append(texts, c.Text())
+		mu.Unlock()
+		return nil
+	})
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "1")))
+	<-started
+
+	handled = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, texts...)
+	}
+	return b, handled, release
+}
+
+func TestOverflowReject(t *testing.T) {
+	var errs []error
+	b, handled, release := blockedBot(t, Settings{
+		Overflow: OverflowReject,
+		OnError:  func(err error, c Context) { errs = append(errs, err) },
+	})
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "2")))
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "3")))
+
+	if s := b.QueueStats(); s.Depth != 1 || s.Rejected != 1 {
+		t.Errorf("expected depth 1 and 1 rejected, got %+v", s)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", errs)
+	}
+
+	close(release)
+	b.running.Wait()
+
+	if got := fmt.Sprint(handled()); got != "[1 2]" {
+		t.Errorf("expected updates [1 2], got %s", got)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	b, handled, release := blockedBot(t, Settings{Overflow: OverflowDropOldest})
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "2")))
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "3")))
+
+	if s := b.QueueStats(); s.Depth != 1 || s.Dropped != 1 {
+		t.Errorf("expected depth 1 and 1 dropped, got %+v", s)
+	}
+
+	close(release)
+	b.running.Wait()
+
+	if got := fmt.Sprint(handled()); got != "[1 3]" {
+		t.Errorf("expected updates [1 3], got %s", got)
+	}
+}
@@ -3,6 +3,7 @@ package tgbot
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 var (
@@ -11,8 +12,69 @@ var (
 	ErrCouldNotUpdate  = errors.New("tgbot: could not fetch new updates")
 	ErrTrueResult      = errors.New("tgbot: result is True")
 	ErrBadContext      = errors.New("tgbot: context does not contain message")
+	ErrBadCallback     = errors.New("tgbot: context does not contain callback")
+	ErrBadQuery        = errors.New("tgbot: context does not contain inline query")
+	ErrQueueFull       = errors.New("tgbot: handler queue is full")
+	ErrNoStateKey      = errors.New("tgbot: context has neither chat nor sender")
+	ErrNoWebhookURL    = errors.New("tgbot: webhook endpoint has no public URL")
 )
 
+// Error is an error returned by the Telegram Bot API.
+type Error struct {
+	// Code is the HTTP-like error_code of the response.
+	Code int
+
+	// Description is a human-readable description of the error.
+	Description string
+
+	// RetryAfter is a number of seconds to wait before repeating
+	// the request, set when flood control is exceeded (429).
+	RetryAfter int
+
+	// MigrateTo is a new identifier of the group, set when
+	// it has been migrated to a supergroup.
+	MigrateTo int64
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("tgbot: %s (%d)", err.Description, err.Code)
+}
+
+// Fatal reports whether repeating the request makes no sense: the token
+// is revoked (401), or updates are already consumed by another
+// poller or webhook (409).
+func (err *Error) Fatal() bool {
+	return err.Code == http.StatusUnauthorized || err.Code == http.StatusConflict
+}
+
+// IsFatal reports whether err is an API error which is fatal for polling.
+func IsFatal(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Fatal()
+}
+
+// ShutdownError is returned by Bot.Shutdown when
+// the context expires before all the work is done.
+type ShutdownError struct {
+	// Err is the error of the expired context.
+	Err error
+
+	// Handlers is the number of handlers still running.
+	Handlers int
+
+	// Updates are the buffered updates left unprocessed.
+	Updates []Update
+}
+
+func (err *ShutdownError) Error() string {
+	return fmt.Sprintf("tgbot: shutdown abandoned %d handlers and %d updates: %v",
+		err.Handlers, len(err.Updates), err.Err)
+}
+
+func (err *ShutdownError) Unwrap() error {
+	return err.Err
+}
+
 // wrapError returns new wrapped error
 func wrapError(err error) error {
 	return fmt.Errorf("tgbot: %w", err)
@@ -0,0 +1,193 @@
+package tgbot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"sync"
+	"time"
+)
+
+// Filter is a predicate deciding whether the handler it is attached
+// to is picked for the update, see When. Any func(Context) bool can
+// be used as a custom filter.
+type Filter func(Context) bool
+
+// Filtered is an endpoint whose handler is picked only when all
+// the filters pass, see When.
+type Filtered struct {
+	Endpoint interface{}
+	Filters  []Filter
+}
+
+// When attaches the filters to any endpoint supported by Handle.
+//
+// The filters are evaluated while the update is routed, before the handler
+// is picked. Filtered handlers of the same endpoint are tried in the order
+// of registration, and the one registered without filters is the fallback.
+// If none of them passes, the routing goes on as if the endpoint had no
+// handler (e.g. OnText or OnUnhandled fire).
+//
+// Example:
+//
+//	b.Handle(tgbot.When("/start", tgbot.ChatType(tgbot.ChatPrivate)), onPrivateStart)
+//	b.Handle(tgbot.When("/start", tgbot.ChatType(tgbot.ChatGroup, tgbot.ChatSuperGroup)), onGroupStart)
+//	b.Handle(tgbot.When("/ban", tgbot.IsAdmin(), tgbot.HasReply()), onBan)
+func When(endpoint interface{}, filters ...Filter) Filtered {
+	return Filtered{Endpoint: endpoint, Filters: filters}
+}
+
+// filteredHandler is a handler registered with filters.
+type filteredHandler struct {
+	filters []Filter
+	handler HandlerFunc
+}
+
+// passes reports whether all the filters pass for the context.
+func passes(filters []Filter, c Context) bool {
+	for _, f := range filters {
+		if !f(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// All passes when all the filters pass.
+func All(filters ...Filter) Filter {
+	return func(c Context) bool {
+		return passes(filters, c)
+	}
+}
+
+// Any passes when at least one of the filters passes.
+func Any(filters ...Filter) Filter {
+	return func(c Context) bool {
+		for _, f := range filters {
+			if f(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not passes when the filter does not.
+func Not(f Filter) Filter {
+	return func(c Context) bool {
+		return !f(c)
+	}
+}
+
+// ChatType passes for updates from the chats of the given types.
+func ChatType(types ...string) Filter {
+	return func(c Context) bool {
+		chat := c.Chat()
+		if chat == nil || chat.Chat == nil {
+			return false
+		}
+		for _, t := range types {
+			if chat.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FromUser passes for updates sent by the given users.
+func FromUser(users ...int64) Filter {
+	return func(c Context) bool {
+		sender := c.Sender()
+		if sender == nil || sender.User == nil {
+			return false
+		}
+		for _, id := range users {
+			if int64(sender.ID) == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasReply passes for messages replying to another message.
+func HasReply() Filter {
+	return func(c Context) bool {
+		m := c.Message()
+		return m != nil && m.Message != nil && m.ReplyToMessage != nil
+	}
+}
+
+// IsForwarded passes for forwarded messages.
+func IsForwarded() Filter {
+	return func(c Context) bool {
+		m := c.Message()
+		return m != nil && m.Message != nil &&
+			(m.ForwardFrom != nil || m.ForwardFromChat != nil || m.ForwardDate != 0)
+	}
+}
+
+// adminCacheTTL is how long IsAdmin trusts a member status.
+const adminCacheTTL = time.Minute
+
+// IsAdmin passes for updates sent by the creator or an administrator
+// of the group or channel. It never passes in private chats.
+//
+// The member status is requested from the API and cached for a minute,
+// the request errors are passed to OnError and are not cached. Unless an
+// ordered dispatch mode is set, the filters are evaluated while the poller
+// waits, so a slow request delays the updates of all the chats.
+func IsAdmin() Filter {
+	type member struct {
+		chatID, userID int64
+	}
+	type status struct {
+		admin   bool
+		expires time.Time
+	}
+
+	var (
+		mu    sync.Mutex
+		cache = make(map[member]status)
+	)
+
+	return func(c Context) bool {
+		chat, sender := c.Chat(), c.Sender()
+		if chat == nil || chat.Chat == nil || sender == nil || sender.User == nil {
+			return false
+		}
+		if chat.IsPrivate() {
+			return false
+		}
+
+		key := member{chatID: chat.ID, userID: int64(sender.ID)}
+		now := time.Now()
+
+		mu.Lock()
+		s, ok := cache[key]
+		mu.Unlock()
+		if ok && now.Before(s.expires) {
+			return s.admin
+		}
+
+		m, err := Call[tgbotapi.ChatMember](c.Bot(), "getChatMember", map[string]any{
+			"chat_id": chat.ID,
+			"user_id": sender.ID,
+		})
+		if err != nil {
+			c.Bot().OnError(err, c)
+			return false
+		}
+		admin := m.IsCreator() || m.IsAdministrator()
+
+		mu.Lock()
+		for k, s := range cache {
+			if now.After(s.expires) {
+				delete(cache, k)
+			}
+		}
+		cache[key] = status{admin: admin, expires: now.Add(adminCacheTTL)}
+		mu.Unlock()
+
+		return admin
+	}
+}
@@ -0,0 +1,28 @@
+package tgbot
+
+import "testing"
+
+func TestIsAdminCache(t *testing.T) {
+	api := newFakeAPI(t)
+	api.result("getChatMember", `{"user":{"id":1,"first_name":"User"},"status":"administrator"}`)
+	b := newTestBot(t, api, Settings{Synchronous: true})
+
+	var handled int
+	b.Handle(When(OnText, IsAdmin()), func(c Context) error {
+		handled++
+		return nil
+	})
+
+	u := decodeUpdate(t, `{"message":{"message_id":1,"date":0,"text":"hi",
+		"from":{"id":1,"first_name":"User"},"chat":{"id":-1,"type":"group"}}}`)
+	for i := 0; i < 3; i++ {
+		b.ProcessUpdate(u)
+	}
+
+	if handled != 3 {
+		t.Errorf("expected 3 handled updates, got %d", handled)
+	}
+	if n := api.called("getChatMember"); n != 1 {
+		t.Errorf("expected 1 getChatMember request, got %d", n)
+	}
+}
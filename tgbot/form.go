@@ -0,0 +1,321 @@
+package tgbot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validator checks the answer to a form question stored in the context
+// and returns its value. The message of the returned error is sent to
+// the user before the question is asked again.
+type Validator func(c Context) (interface{}, error)
+
+// Question is a single step of a Form.
+type Question struct {
+	// Key is the name of the answer in FormAnswers.
+	Key string
+
+	// Text is the question sent to the user.
+	Text string
+
+	// Choices, if set, are offered as an inline keyboard,
+	// and the answer must be one of them.
+	Choices []string
+
+	// Validate checks the answer. If nil, any non-empty text is accepted,
+	// or one of the Choices if they are set.
+	Validate Validator
+}
+
+// FormAnswers maps the question keys to the validated answers.
+type FormAnswers map[string]interface{}
+
+// String returns the answer as a string, or an empty string
+// if it is missing or is not a string.
+func (a FormAnswers) String(key string) string {
+	s, _ := a[key].(string)
+	return s
+}
+
+// Form is a declarative multi-step dialog collecting validated user
+// input, built on top of the conversation states (see HandleState).
+// While a form is in progress, its answers, including the presses of
+// the choice buttons, are taken before the global pattern endpoints.
+//
+// As the state is looked up when the update is routed, forms require
+// Synchronous or one of the ordered dispatch modes (see DispatchPerChat).
+// With the default concurrent dispatch, an answer sent right after the
+// previous one may be routed before the form has moved on.
+//
+// Example:
+//
+//	onboarding := &tgbot.Form{
+//		Name: "onboarding",
+//		Questions: []tgbot.Question{
+//			{Key: "name", Text: "What is your name?"},
+//			{Key: "age", Text: "How old are you?", Validate: tgbot.NumberRange(1, 120)},
+//			{Key: "plan", Text: "Pick a plan", Choices: []string{"free", "pro"}},
+//			{Key: "phone", Text: "Share your contact", Validate: tgbot.IsContact()},
+//			{Key: "photo", Text: "Send your photo", Validate: tgbot.IsPhoto()},
+//		},
+//		OnComplete: func(c tgbot.Context, answers tgbot.FormAnswers) error {
+//			return c.Send("Welcome, " + answers.String("name"))
+//		},
+//	}
+//
+//	b.HandleForm("/start", onboarding)
+type Form struct {
+	// Name identifies the form, it must be unique within the bot.
+	Name string
+
+	Questions []Question
+
+	// OnComplete is called with all the answers once
+	// the last question is answered.
+	OnComplete func(Context, FormAnswers) error
+
+	// OnCancel is called when the user cancels the form.
+	// If nil, CancelText is sent instead.
+	OnCancel HandlerFunc
+
+	// CancelCommand cancels the form, defaulted to /cancel.
+	CancelCommand string
+
+	// CancelText is sent on cancel, defaulted to "Cancelled.".
+	CancelText string
+}
+
+const formStepKey = "\aform_step"
+
+// HandleForm registers the form: the endpoint starts it, and its
+// answers are handled in the form state until it is completed or
+// cancelled. Middleware is applied to all the handlers of the form.
+func (b *Bot) HandleForm(endpoint interface{}, f *Form, m ...MiddlewareFunc) {
+	if len(f.Questions) == 0 {
+		panic("tgbot: form has no questions")
+	}
+
+	state := f.state()
+
+	b.Handle(endpoint, f.start, m...)
+	for _, end := range []string{OnText, OnContact, OnLocation, OnPhoto, OnMedia, OnCallback} {
+		b.HandleState(state, end, f.answer, m...)
+	}
+	b.HandleState(state, f.cancelCommand(), f.cancel, m...)
+}
+
+func (f *Form) state() State {
+	return State("\aform:" + f.Name)
+}
+
+func (f *Form) cancelCommand() string {
+	if f.CancelCommand != "" {
+		return f.CancelCommand
+	}
+	return "/cancel"
+}
+
+func (f *Form) start(c Context) error {
+	if err := c.ResetState(); err != nil {
+		return err
+	}
+	if err := c.SetState(f.state()); err != nil {
+		return err
+	}
+	if err := c.SetStateData(formStepKey, 0); err != nil {
+		return err
+	}
+	return f.ask(c, 0)
+}
+
+func (f *Form) ask(c Context, step int) error {
+	q := f.Questions[step]
+	if len(q.Choices) == 0 {
+		return c.Send(q.Text)
+	}
+
+	markup := &ReplyMarkup{}
+	for _, choice := range q.Choices {
+		markup.InlineKeyboard = append(markup.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(choice, choice)))
+	}
+	return c.Send(q.Text, markup)
+}
+
+func (f *Form) answer(c Context) error {
+	if c.Callback() != nil {
+		if err := c.Respond(); err != nil {
+			c.Bot().OnError(err, c)
+		}
+	}
+
+	// Storages serializing the data to JSON bring numbers back as float64.
+	var step int
+	switch v := c.StateData(formStepKey).(type) {
+	case int:
+		step = v
+	case float64:
+		step = int(v)
+	}
+	if step < 0 || step >= len(f.Questions) {
+		return c.ResetState()
+	}
+
+	q := f.Questions[step]
+
+	validate := q.Validate
+	if validate == nil {
+		if len(q.Choices) > 0 {
+			validate = OneOf(q.Choices...)
+		} else {
+			validate = NonEmpty()
+		}
+	}
+
+	value, err := validate(c)
+	if err != nil {
+		if err := c.Send(err.Error()); err != nil {
+			return err
+		}
+		return f.ask(c, step)
+	}
+
+	if err := c.SetStateData(q.Key, value); err != nil {
+		return err
+	}
+
+	step++
+	if step < len(f.Questions) {
+		if err := c.SetStateData(formStepKey, step); err != nil {
+			return err
+		}
+		return f.ask(c, step)
+	}
+
+	answers := make(FormAnswers, len(f.Questions))
+	for _, q := range f.Questions {
+		answers[q.Key] = c.StateData(q.Key)
+	}
+
+	if err := c.ResetState(); err != nil {
+		return err
+	}
+	if f.OnComplete == nil {
+		return nil
+	}
+	return f.OnComplete(c, answers)
+}
+
+func (f *Form) cancel(c Context) error {
+	if err := c.ResetState(); err != nil {
+		return err
+	}
+	if f.OnCancel != nil {
+		return f.OnCancel(c)
+	}
+
+	text := f.CancelText
+	if text == "" {
+		text = "Cancelled."
+	}
+	return c.Send(text)
+}
+
+// answerText returns the text of the message or the data
+// of the callback answering the question.
+func answerText(c Context) string {
+	if cb := c.Callback(); cb != nil {
+		return cb.Data
+	}
+	if m := c.Message(); m != nil && m.Message != nil {
+		return strings.TrimSpace(m.Text)
+	}
+	return ""
+}
+
+// NonEmpty accepts any non-empty text.
+func NonEmpty() Validator {
+	return func(c Context) (interface{}, error) {
+		text := answerText(c)
+		if text == "" {
+			return nil, errors.New("Please answer with a text.")
+		}
+		return text, nil
+	}
+}
+
+// MatchRegexp accepts a text matching rx. The hint is sent
+// to the user if it does not match.
+func MatchRegexp(rx *regexp.Regexp, hint string) Validator {
+	return func(c Context) (interface{}, error) {
+		text := answerText(c)
+		if !rx.MatchString(text) {
+			return nil, errors.New(hint)
+		}
+		return text, nil
+	}
+}
+
+// NumberRange accepts a number between from and to inclusive,
+// the answer value is float64.
+func NumberRange(from, to float64) Validator {
+	return func(c Context) (interface{}, error) {
+		n, err := strconv.ParseFloat(strings.ReplaceAll(answerText(c), ",", "."), 64)
+		if err != nil || n < from || n > to {
+			return nil, fmt.Errorf("Please enter a number from %v to %v.", from, to)
+		}
+		return n, nil
+	}
+}
+
+// OneOf accepts one of the choices, either typed or
+// picked from the keyboard.
+func OneOf(choices ...string) Validator {
+	return func(c Context) (interface{}, error) {
+		text := answerText(c)
+		for _, choice := range choices {
+			if text == choice {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("Please choose one of: %s.", strings.Join(choices, ", "))
+	}
+}
+
+// IsContact accepts a shared contact,
+// the answer value is *tgbotapi.Contact.
+func IsContact() Validator {
+	return func(c Context) (interface{}, error) {
+		if m := c.Message(); m != nil && m.Message != nil && m.Contact != nil {
+			return m.Contact, nil
+		}
+		return nil, errors.New("Please share a contact.")
+	}
+}
+
+// IsLocation accepts a shared location,
+// the answer value is *tgbotapi.Location.
+func IsLocation() Validator {
+	return func(c Context) (interface{}, error) {
+		if m := c.Message(); m != nil && m.Message != nil && m.Location != nil {
+			return m.Location, nil
+		}
+		return nil, errors.New("Please share a location.")
+	}
+}
+
+// IsPhoto accepts a photo, the answer value is
+// the file ID of its largest size.
+func IsPhoto() Validator {
+	return func(c Context) (interface{}, error) {
+		if m := c.Message(); m != nil && m.Message != nil && m.Photo != nil && len(*m.Photo) > 0 {
+			sizes := *m.Photo
+			return sizes[len(sizes)-1].FileID, nil
+		}
+		return nil, errors.New("Please send a photo.")
+	}
+}
@@ -0,0 +1,42 @@
+package tgbot
+
+import "testing"
+
+func TestFormBeforePatterns(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{Synchronous: true})
+
+	b.Handle(Prefix("order "), func(c Context) error {
+		t.Error("the answer is taken by a global prefix")
+		return nil
+	})
+	b.Handle(CallbackPrefix("p"), func(c Context) error {
+		t.Error("the choice is taken by a global callback prefix")
+		return nil
+	})
+
+	var answers FormAnswers
+	b.HandleForm("/order", &Form{
+		Name: "order",
+		Questions: []Question{
+			{Key: "what", Text: "What would you like?"},
+			{Key: "plan", Text: "Pick a plan", Choices: []string{"plain", "pro"}},
+		},
+		OnComplete: func(c Context, a FormAnswers) error {
+			answers = a
+			return nil
+		},
+	})
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "/order")))
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "order pizza")))
+	b.ProcessUpdate(decodeUpdate(t, `{"callback_query":{"id":"1",
+		"from":{"id":1,"first_name":"User"},"chat_instance":"1","data":"pro",
+		"message":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}}`))
+
+	if answers == nil {
+		t.Fatal("the form is not completed")
+	}
+	if answers.String("what") != "order pizza" || answers.String("plan") != "pro" {
+		t.Errorf("unexpected answers %v", answers)
+	}
+}
@@ -0,0 +1,156 @@
+package tgbot
+
+import (
+	"sync"
+	"time"
+)
+
+// State is a name of a conversation state. The empty
+// state means there is no conversation in progress.
+type State string
+
+// StateKey identifies a conversation: a user in a chat.
+type StateKey struct {
+	ChatID int64
+	UserID int64
+}
+
+// StateRecord is what StateStorage keeps for a conversation.
+type StateRecord struct {
+	State State
+
+	// Data is arbitrary data bound to the conversation. Storages
+	// persisting it out of process must be able to serialize it.
+	Data map[string]interface{}
+
+	// Expires is the moment the state times out,
+	// zero if it never does.
+	Expires time.Time
+}
+
+// Expired reports whether the record has timed out by now.
+func (r *StateRecord) Expired() bool {
+	return !r.Expires.IsZero() && time.Now().After(r.Expires)
+}
+
+// StateStorage keeps the conversation states.
+type StateStorage interface {
+	// Get returns the record of the conversation,
+	// or a zero record if there is none.
+	Get(key StateKey) (StateRecord, error)
+
+	// Set saves the record of the conversation.
+	Set(key StateKey, rec StateRecord) error
+
+	// Delete removes the record of the conversation.
+	Delete(key StateKey) error
+}
+
+// MemoryStateStorage keeps the conversation states in memory.
+// Expired records are removed on access.
+type MemoryStateStorage struct {
+	mu      sync.Mutex
+	records map[StateKey]StateRecord
+}
+
+// NewMemoryStateStorage returns an empty in-memory state storage.
+func NewMemoryStateStorage() *MemoryStateStorage {
+	return &MemoryStateStorage{records: make(map[StateKey]StateRecord)}
+}
+
+func (s *MemoryStateStorage) Get(key StateKey) (StateRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok {
+		return StateRecord{}, nil
+	}
+	if rec.Expired() {
+		delete(s.records, key)
+		return StateRecord{}, nil
+	}
+	return rec, nil
+}
+
+func (s *MemoryStateStorage) Set(key StateKey, rec StateRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = rec
+	return nil
+}
+
+func (s *MemoryStateStorage) Delete(key StateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// HandleState lets you set the handler for the endpoint which fires
+// only while the conversation is in the given state (see Context.State).
+//
+// State handlers take precedence over the handlers of the same endpoint
+// registered by Handle, and OnText and OnCallback state handlers take the
+// updates before the global pattern endpoints (see Prefix). Only strings
+// and callback endpoints are supported.
+//
+// The state is looked up when the update is routed, so the updates of
+// one conversation must not be processed in parallel: use Synchronous
+// or one of the ordered dispatch modes (see DispatchPerChat).
+//
+// Example:
+//
+//	b.Handle("/register", func(c tgbot.Context) error {
+//		c.SetState("name")
+//		return c.Send("What is your name?")
+//	})
+//
+//	b.HandleState("name", tgbot.OnText, func(c tgbot.Context) error {
+//		c.ResetState()
+//		return c.Send("Hello, " + c.Text())
+//	})
+func (b *Bot) HandleState(state State, endpoint interface{}, h HandlerFunc, m ...MiddlewareFunc) {
+	m = appendMiddleware(b.middleware, m)
+
+	handler := func(c Context) error {
+		return applyMiddleware(h, m...)(c)
+	}
+
+	handlers, ok := b.stateHandlers[state]
+	if !ok {
+		handlers = make(map[string]HandlerFunc)
+		b.stateHandlers[state] = handlers
+	}
+
+	switch end := endpoint.(type) {
+	case string:
+		handlers[end] = handler
+	case CallbackEndpoint:
+		handlers[end.CallbackUnique()] = handler
+	default:
+		panic("tgbot: unsupported endpoint")
+	}
+}
+
+// HandleState adds the state endpoint handler to the bot, combining
+// group's middleware with the optional given middleware.
+func (g *Group) HandleState(state State, endpoint interface{}, h HandlerFunc, m ...MiddlewareFunc) {
+	g.b.HandleState(state, endpoint, h, appendMiddleware(g.middleware, m)...)
+}
+
+// SetStateTimeout makes the conversations entering the state time out
+// after d, after which they are back to the empty state. Zero disables
+// the timeout.
+func (b *Bot) SetStateTimeout(state State, d time.Duration) {
+	if d <= 0 {
+		delete(b.stateTimeouts, state)
+		return
+	}
+	b.stateTimeouts[state] = d
+}
+
+// States returns the storage of conversation states.
+func (b *Bot) States() StateStorage {
+	return b.states
+}
@@ -0,0 +1,88 @@
+package tgbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStateInlineCallback(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{Synchronous: true})
+
+	var fired []string
+	b.Handle(OnAny, func(c Context) error {
+		fired = append(fired, "any")
+		return nil
+	})
+	b.Handle(OnCallback, func(c Context) error {
+		fired = append(fired, "callback")
+		return nil
+	})
+	b.HandleState("voting", OnCallback, func(c Context) error {
+		fired = append(fired, "voting")
+		return c.ResetState()
+	})
+
+	u := decodeUpdate(t, inlineCallback)
+	b.ProcessUpdate(u)
+
+	// Without a chat, the conversation is keyed by the sender alone.
+	err := b.States().Set(StateKey{UserID: 42}, StateRecord{State: "voting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ProcessUpdate(u)
+	b.ProcessUpdate(u)
+
+	want := []string{"any", "callback", "any", "voting", "any", "callback"}
+	if len(fired) != len(want) {
+		t.Fatalf("expected %v, got %v", want, fired)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, fired)
+		}
+	}
+}
+
+func TestStateBeforePatterns(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{Synchronous: true})
+
+	var fired []string
+	b.Handle(Prefix("order "), func(c Context) error {
+		fired = append(fired, "order")
+		return nil
+	})
+	b.Handle(CallbackPrefix("size:"), func(c Context) error {
+		fired = append(fired, "size")
+		return nil
+	})
+	b.HandleState("form", OnText, func(c Context) error {
+		fired = append(fired, "form:"+c.Text())
+		return nil
+	})
+	b.HandleState("form", OnCallback, func(c Context) error {
+		fired = append(fired, "form:"+c.Callback().Data)
+		return c.ResetState()
+	})
+
+	text := decodeUpdate(t, textMessage(1, "order pizza"))
+	callback := decodeUpdate(t, `{"callback_query":{"id":"1",
+		"from":{"id":1,"first_name":"User"},"chat_instance":"1","data":"size:xl",
+		"message":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}}`)
+
+	err := b.States().Set(StateKey{ChatID: 1, UserID: 1}, StateRecord{State: "form"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ProcessUpdate(text)
+	b.ProcessUpdate(callback)
+
+	// The form is over, so the patterns are back.
+	b.ProcessUpdate(text)
+	b.ProcessUpdate(callback)
+
+	want := "form:order pizza,form:size:xl,order,size"
+	if got := strings.Join(fired, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,268 @@
+package tgbot
+
+import (
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"net/url"
+	"strconv"
+)
+
+// Query is an incoming inline query. When the user sends
+// an empty query, your bot could return some default or
+// trending results.
+type Query struct {
+	*tgbotapi.InlineQuery
+}
+
+// InlineResult represents a result of an inline query that was chosen
+// by the user and sent to their chat partner.
+type InlineResult struct {
+	*tgbotapi.ChosenInlineResult
+}
+
+// Result represents one result of an inline query.
+type Result interface {
+	// ResultType returns the type of the result,
+	// e.g. "article" or "photo".
+	ResultType() string
+}
+
+// Results is a slice wrapper for convenient marshalling.
+type Results []Result
+
+// ArticleResult represents a link to an article or web page.
+type ArticleResult struct {
+	// Unique identifier for this result, 1-64 Bytes.
+	ID string `json:"id"`
+
+	// Title of the result.
+	Title string `json:"title"`
+
+	// Text of the message sent when the result is chosen.
+	Text string `json:"-"`
+
+	// ParseMode of the Text.
+	ParseMode ParseMode `json:"-"`
+
+	// Optional. Short description of the result.
+	Description string `json:"description,omitempty"`
+
+	// Optional. URL of the result.
+	URL string `json:"url,omitempty"`
+
+	// Optional. URL of the thumbnail for the result.
+	ThumbURL string `json:"thumb_url,omitempty"`
+
+	// Optional. Inline keyboard attached to the message.
+	ReplyMarkup *ReplyMarkup `json:"-"`
+}
+
+func (r *ArticleResult) ResultType() string {
+	return "article"
+}
+
+func (r *ArticleResult) MarshalJSON() ([]byte, error) {
+	type article ArticleResult
+	return json.Marshal(struct {
+		Type                string                           `json:"type"`
+		InputMessageContent tgbotapi.InputTextMessageContent `json:"input_message_content"`
+		ReplyMarkup         *tgbotapi.InlineKeyboardMarkup   `json:"reply_markup,omitempty"`
+		*article
+	}{
+		Type: r.ResultType(),
+		InputMessageContent: tgbotapi.InputTextMessageContent{
+			Text:      r.Text,
+			ParseMode: r.ParseMode,
+		},
+		ReplyMarkup: inlineMarkup(r.ReplyMarkup),
+		article:     (*article)(r),
+	})
+}
+
+// PhotoResult represents a link to a photo.
+type PhotoResult struct {
+	// Unique identifier for this result, 1-64 Bytes.
+	ID string `json:"id"`
+
+	// A valid URL of the photo. Photo must be in jpeg format.
+	// Photo size must not exceed 5MB.
+	URL string `json:"photo_url"`
+
+	// URL of the thumbnail for the photo.
+	ThumbURL string `json:"thumb_url"`
+
+	// Optional. Title for the result.
+	Title string `json:"title,omitempty"`
+
+	// Optional. Short description of the result.
+	Description string `json:"description,omitempty"`
+
+	// Optional. Caption of the photo to be sent, 0-1024 characters.
+	Caption string `json:"caption,omitempty"`
+
+	// Optional. Inline keyboard attached to the message.
+	ReplyMarkup *ReplyMarkup `json:"-"`
+}
+
+func (r *PhotoResult) ResultType() string {
+	return "photo"
+}
+
+func (r *PhotoResult) MarshalJSON() ([]byte, error) {
+	type photo PhotoResult
+	return json.Marshal(struct {
+		Type        string                         `json:"type"`
+		ReplyMarkup *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+		*photo
+	}{
+		Type:        r.ResultType(),
+		ReplyMarkup: inlineMarkup(r.ReplyMarkup),
+		photo:       (*photo)(r),
+	})
+}
+
+// DocumentResult represents a link to a file.
+type DocumentResult struct {
+	// Unique identifier for this result, 1-64 Bytes.
+	ID string `json:"id"`
+
+	// Title for the result.
+	Title string `json:"title"`
+
+	// A valid URL for the file
+	URL string `json:"document_url"`
+
+	// Mime type of the content of the file, either “application/pdf”
+	// or “application/zip”.
+	MIME string `json:"mime_type"`
+
+	// Optional. Caption of the document to be sent, 0-200 characters.
+	Caption string `json:"caption,omitempty"`
+
+	// Optional. Short description of the result.
+	Description string `json:"description,omitempty"`
+
+	// Optional. URL of the thumbnail (jpeg only) for the file.
+	ThumbURL string `json:"thumb_url,omitempty"`
+
+	// Optional. Inline keyboard attached to the message.
+	ReplyMarkup *ReplyMarkup `json:"-"`
+}
+
+func (r *DocumentResult) ResultType() string {
+	return "document"
+}
+
+func (r *DocumentResult) MarshalJSON() ([]byte, error) {
+	type document DocumentResult
+	return json.Marshal(struct {
+		Type        string                         `json:"type"`
+		ReplyMarkup *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+		*document
+	}{
+		Type:        r.ResultType(),
+		ReplyMarkup: inlineMarkup(r.ReplyMarkup),
+		document:    (*document)(r),
+	})
+}
+
+func inlineMarkup(r *ReplyMarkup) *tgbotapi.InlineKeyboardMarkup {
+	if r == nil {
+		return nil
+	}
+	return &r.InlineKeyboardMarkup
+}
+
+// QueryResponse builds a response to an inline Query.
+type QueryResponse struct {
+	// The results for the inline query.
+	Results Results
+
+	// (Optional) The maximum amount of time in seconds that the result
+	// of the inline query may be cached on the server.
+	CacheTime int
+
+	// (Optional) Pass True, if results may be cached on the server side
+	// only for the user that sent the query. By default, results may
+	// be returned to any user who sends the same query.
+	IsPersonal bool
+
+	// (Optional) Pass the offset that a client should send in the next
+	// query with the same text to receive more results. Pass an empty
+	// string if there are no more results or if you don‘t support
+	// pagination. Offset length can’t exceed 64 bytes.
+	NextOffset string
+
+	// (Optional) If set, Results holds all the results, and only the page
+	// of PageSize results starting at the offset of the query is sent,
+	// with NextOffset set automatically. Telegram allows at most 50.
+	PageSize int
+
+	// (Optional) If passed, clients will display a button with specified
+	// text that switches the user to a private chat with the bot and sends
+	// the bot a start message with the parameter switch_pm_parameter.
+	SwitchPMText string
+
+	// (Optional) Parameter for the start message sent to the bot when user
+	// presses the switch button.
+	SwitchPMParameter string
+}
+
+// page returns the results to send in response to the query
+// with the given offset, and the offset of the next page.
+func (r *QueryResponse) page(offset string) (Results, string) {
+	if r.PageSize <= 0 {
+		return r.Results, r.NextOffset
+	}
+
+	start, _ := strconv.Atoi(offset)
+	if start < 0 || start > len(r.Results) {
+		start = len(r.Results)
+	}
+
+	end := start + r.PageSize
+	if end >= len(r.Results) {
+		return r.Results[start:], ""
+	}
+	return r.Results[start:end], strconv.Itoa(end)
+}
+
+// Answer sends a response for a given inline query. A query can only
+// be responded to once, subsequent attempts to respond to the same query
+// will result in an error.
+func (b *Bot) Answer(query *Query, resp *QueryResponse) error {
+	if query == nil || query.InlineQuery == nil {
+		return ErrBadQuery
+	}
+	if resp == nil {
+		resp = &QueryResponse{}
+	}
+
+	results, next := resp.page(query.Offset)
+	if results == nil {
+		results = Results{}
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	v := url.Values{}
+	v.Set("inline_query_id", query.ID)
+	v.Set("results", string(data))
+	v.Set("cache_time", strconv.Itoa(resp.CacheTime))
+	if resp.IsPersonal {
+		v.Set("is_personal", "true")
+	}
+	if next != "" {
+		v.Set("next_offset", next)
+	}
+	if resp.SwitchPMText != "" {
+		v.Set("switch_pm_text", resp.SwitchPMText)
+		v.Set("switch_pm_parameter", resp.SwitchPMParameter)
+	}
+
+	_, err = b.request("answerInlineQuery", v)
+	return err
+}
@@ -5,4 +5,11 @@ import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 type Message struct {
 	*tgbotapi.Message
 	Payload string
+
+	// Via is the bot through which the message was sent.
+	Via *User
+
+	// AlbumID is the media group ID of the album
+	// the message belongs to, see OnAlbum.
+	AlbumID string
 }
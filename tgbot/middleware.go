@@ -0,0 +1,49 @@
+package tgbot
+
+// MiddlewareFunc represents a middleware processing function,
+// which get called before the endpoint group or specific handler.
+type MiddlewareFunc func(HandlerFunc) HandlerFunc
+
+func appendMiddleware(a, b []MiddlewareFunc) []MiddlewareFunc {
+	if len(a) == 0 {
+		return b
+	}
+
+	m := make([]MiddlewareFunc, 0, len(a)+len(b))
+	return append(append(m, a...), b...)
+}
+
+// applyMiddleware wraps h so that the first middleware
+// is the outermost one, i.e. it is called first.
+func applyMiddleware(h HandlerFunc, m ...MiddlewareFunc) HandlerFunc {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// Group is a separated group of handlers, united by the general middleware.
+//
+// The group middleware is called after the global bot middleware
+// and before the middleware passed to Group.Handle.
+type Group struct {
+	b          *Bot
+	middleware []MiddlewareFunc
+}
+
+// Group returns a new group of handlers sharing the same middleware.
+func (b *Bot) Group() *Group {
+	return &Group{b: b}
+}
+
+// Use adds middleware to the group chain. Like with Bot.Use,
+// it wraps only the handlers registered after the call.
+func (g *Group) Use(middleware ...MiddlewareFunc) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// Handle adds endpoint handler to the bot, combining group's middleware
+// with the optional given middleware.
+func (g *Group) Handle(endpoint interface{}, h HandlerFunc, m ...MiddlewareFunc) {
+	g.b.Handle(endpoint, h, appendMiddleware(g.middleware, m)...)
+}
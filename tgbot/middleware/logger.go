@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/curserio/tg-bot-gateway/tgbot"
+	"log"
+	"time"
+)
+
+// Logger returns a middleware that logs every handled update
+// along with the time spent on it and the handler error.
+// The standard logger is used if no logger is passed.
+func Logger(logger ...*log.Logger) tgbot.MiddlewareFunc {
+	var l *log.Logger
+	if len(logger) > 0 {
+		l = logger[0]
+	} else {
+		l = log.Default()
+	}
+
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		return func(c tgbot.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			var senderID, chatID int64
+			if sender := c.Sender(); sender != nil && sender.User != nil {
+				senderID = int64(sender.ID)
+			}
+			if chat := c.Chat(); chat != nil && chat.Chat != nil {
+				chatID = chat.ID
+			}
+
+			l.Printf("update=%d sender=%d chat=%d text=%q took=%s err=%v",
+				c.Update().UpdateID, senderID, chatID, c.Text(), time.Since(start), err)
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/curserio/tg-bot-gateway/tgbot"
+)
+
+// AutoRespond returns a middleware that automatically responds
+// to every callback after the handler is finished.
+func AutoRespond() tgbot.MiddlewareFunc {
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		return func(c tgbot.Context) error {
+			if c.Callback() != nil {
+				defer c.Respond()
+			}
+			return next(c)
+		}
+	}
+}
+
+// IgnoreVia returns a middleware that ignores all the
+// "sent via" messages.
+func IgnoreVia() tgbot.MiddlewareFunc {
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		return func(c tgbot.Context) error {
+			if msg := c.Message(); msg != nil && msg.Via != nil {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// IgnoreForwarded returns a middleware that ignores
+// all the forwarded messages.
+func IgnoreForwarded() tgbot.MiddlewareFunc {
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		return func(c tgbot.Context) error {
+			if msg := c.Message(); msg != nil && msg.Message != nil && msg.ForwardDate != 0 {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// Recover returns a middleware that recovers a panic happened in
+// the handler. The panic is reported as an error to the onError
+// callback, or to Bot.OnError if it is not passed.
+func Recover(onError ...func(error, tgbot.Context)) tgbot.MiddlewareFunc {
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		return func(c tgbot.Context) error {
+			var f func(error, tgbot.Context)
+			if len(onError) > 0 {
+				f = onError[0]
+			} else {
+				f = c.Bot().OnError
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					if err, ok := r.(error); ok {
+						f(err, c)
+					} else if s, ok := r.(string); ok {
+						f(errors.New(s), c)
+					} else {
+						f(fmt.Errorf("tgbot: panic: %v", r), c)
+					}
+				}
+			}()
+
+			return next(c)
+		}
+	}
+}
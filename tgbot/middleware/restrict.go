@@ -0,0 +1,86 @@
+package middleware
+
+import "github.com/curserio/tg-bot-gateway/tgbot"
+
+// RestrictConfig defines config for Restrict middleware.
+type RestrictConfig struct {
+	// Users is a list of user IDs, matched against the sender.
+	Users []int64
+
+	// Chats is a list of chat IDs, matched against the current chat.
+	Chats []int64
+
+	// In defines a function that will be called if the sender
+	// or the chat is in the lists.
+	In tgbot.HandlerFunc
+
+	// Out defines a function that will be called if neither
+	// the sender nor the chat is in the lists.
+	Out tgbot.HandlerFunc
+}
+
+// Restrict builds a middleware which checks the sender and the chat
+// against the lists and calls In or Out accordingly. A nil In or Out
+// is replaced with the next handler.
+func Restrict(v RestrictConfig) tgbot.MiddlewareFunc {
+	return func(next tgbot.HandlerFunc) tgbot.HandlerFunc {
+		in, out := v.In, v.Out
+		if in == nil {
+			in = next
+		}
+		if out == nil {
+			out = next
+		}
+
+		return func(c tgbot.Context) error {
+			if sender := c.Sender(); sender != nil && sender.User != nil {
+				if contains(v.Users, int64(sender.ID)) {
+					return in(c)
+				}
+			}
+			if chat := c.Chat(); chat != nil && chat.Chat != nil {
+				if contains(v.Chats, chat.ID) {
+					return in(c)
+				}
+			}
+			return out(c)
+		}
+	}
+}
+
+// Whitelist returns a middleware that skips the update
+// unless it is sent by one of the given users.
+func Whitelist(users ...int64) tgbot.MiddlewareFunc {
+	return Restrict(RestrictConfig{Users: users, Out: ignore})
+}
+
+// Blacklist returns a middleware that skips the update
+// if it is sent by one of the given users.
+func Blacklist(users ...int64) tgbot.MiddlewareFunc {
+	return Restrict(RestrictConfig{Users: users, In: ignore})
+}
+
+// WhitelistChats returns a middleware that skips the update
+// unless it happened in one of the given chats.
+func WhitelistChats(chats ...int64) tgbot.MiddlewareFunc {
+	return Restrict(RestrictConfig{Chats: chats, Out: ignore})
+}
+
+// BlacklistChats returns a middleware that skips the update
+// if it happened in one of the given chats.
+func BlacklistChats(chats ...int64) tgbot.MiddlewareFunc {
+	return Restrict(RestrictConfig{Chats: chats, In: ignore})
+}
+
+func ignore(tgbot.Context) error {
+	return nil
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
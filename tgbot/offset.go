@@ -0,0 +1,97 @@
+package tgbot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OffsetStore persists the ID of the last update handed over to
+// ProcessUpdate, so that a restarted LongPoller continues right
+// after it instead of replaying or skipping updates.
+type OffsetStore interface {
+	// Load returns the last committed update ID,
+	// or zero if nothing has been committed yet.
+	Load() (int, error)
+
+	// Save commits the given update ID.
+	Save(updateID int) error
+}
+
+// OffsetCommitter is implemented by pollers which need to be notified
+// once an update has been handed over to ProcessUpdate.
+type OffsetCommitter interface {
+	Commit(updateID int)
+}
+
+// MemoryOffsetStore keeps the offset in memory only.
+// It is mostly useful for tests or when sharing the offset
+// between several pollers of one process.
+type MemoryOffsetStore struct {
+	mu       sync.Mutex
+	updateID int
+}
+
+func (s *MemoryOffsetStore) Load() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateID, nil
+}
+
+func (s *MemoryOffsetStore) Save(updateID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateID = updateID
+	return nil
+}
+
+// FileOffsetStore keeps the offset in a plain text file at Path.
+// The file is replaced atomically on every save.
+type FileOffsetStore struct {
+	Path string
+}
+
+func (s *FileOffsetStore) Load() (int, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return 0, nil
+	}
+
+	updateID, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	return updateID, nil
+}
+
+func (s *FileOffsetStore) Save(updateID int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return wrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.Itoa(updateID)); err != nil {
+		tmp.Close()
+		return wrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return wrapError(err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package tgbot
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Prefix is an endpoint matching text messages starting with it.
+// The rest of the text is available as Context.Data.
+//
+//	b.Handle(tgbot.Prefix("order "), onOrder)
+type Prefix string
+
+// CallbackPrefix is an endpoint matching callbacks whose data
+// starts with it. The rest of the data is available as Context.Data.
+//
+//	b.Handle(tgbot.CallbackPrefix("buy:"), onBuy)
+type CallbackPrefix string
+
+// CallbackRegexp is an endpoint matching callback data
+// by a regular expression, see also *regexp.Regexp endpoints.
+//
+//	b.Handle(tgbot.CallbackRegexp{rx}, onBuy)
+type CallbackRegexp struct {
+	*regexp.Regexp
+}
+
+// pattern is a registered prefix or regexp endpoint.
+type pattern struct {
+	callback bool
+	prefix   string
+	rx       *regexp.Regexp
+	handler  HandlerFunc
+	filters  []Filter
+}
+
+// match returns the submatches of the pattern against s,
+// or nil if s does not match.
+func (p *pattern) match(s string) []string {
+	if p.rx != nil {
+		return p.rx.FindStringSubmatch(s)
+	}
+	if strings.HasPrefix(s, p.prefix) {
+		return []string{s, strings.TrimPrefix(s, p.prefix)}
+	}
+	return nil
+}
+
+// handlePattern runs the first registered pattern matching s.
+// Patterns are tried in the order of registration.
+func (b *Bot) handlePattern(s string, callback bool, c Context) bool {
+	for i := range b.patterns {
+		p := &b.patterns[i]
+		if p.callback != callback {
+			continue
+		}
+
+		matches := p.match(s)
+		if matches == nil || !passes(p.filters, c) {
+			continue
+		}
+
+		u := c.Update()
+		if u.AdditionalUpdateParams != nil {
+			u.Matches = matches
+			u.matchNames = nil
+			if p.rx != nil {
+				u.matchNames = p.rx.SubexpNames()
+			} else {
+				u.Payload = matches[1]
+			}
+		}
+
+		b.runHandler(p.handler, c)
+		return true
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package tgbot
 
 import (
+	"errors"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -24,10 +27,104 @@ type LongPoller struct {
 	Limit        int
 	Timeout      time.Duration
 	LastUpdateID int
+
+	// Store makes the offset durable across restarts. It is loaded
+	// when polling starts and saved only for the updates which have
+	// already been handed over to ProcessUpdate. Telegram is asked for
+	// the updates after the committed one as well, so the updates still
+	// waiting in Bot.Updates are not confirmed to it; LastUpdateID only
+	// skips the ones already delivered by this poller.
+	//
+	// If the process crashes, the updates which have not been passed
+	// through ProcessUpdate yet are delivered again after the restart,
+	// i.e. delivery is at-least-once. Handlers running at the moment
+	// of the crash are not repeated, unless Synchronous is set, as then
+	// ProcessUpdate returns only after the handler. On a graceful
+	// shutdown (see Bot.Shutdown), the updates left in Bot.Updates are
+	// processed and committed, so nothing is lost or repeated.
+	//
+	// Pollers wrapping the LongPoller must commit the updates they do
+	// not pass on, as MiddlewarePoller does, otherwise polling stalls
+	// once a whole page of the updates is dropped.
+	Store OffsetStore
+
+	// MinBackoff and MaxBackoff bound the exponential delay between
+	// failed getUpdates requests, defaulted to 1 second and 1 minute.
+	// Flood control errors are delayed for the retry_after instead.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// AllowedUpdates lists the update types to receive, see Update*
+	// constants. Nil keeps the subscription of the previous request,
+	// while an empty list resets it to the Telegram default.
+	AllowedUpdates []string
+
+	// AutoAllowedUpdates derives AllowedUpdates from the handlers
+	// registered by the moment polling starts (see Bot.AllowedUpdates).
+	AutoAllowedUpdates bool
+
+	mu        sync.Mutex
+	bot       *Bot
+	polling   bool
+	committed int
+	saved     int
+	progress  chan struct{}
+	state     PollerState
+}
+
+// PollerState is a snapshot of the poller health.
+type PollerState struct {
+	// LastSuccess is the time of the last successful request.
+	LastSuccess time.Time
+
+	// ConsecutiveFailures is the number of requests
+	// failed in a row since the last successful one.
+	ConsecutiveFailures int
+
+	// LastError is the error of the last failed request.
+	LastError error
+
+	// Stopped is set when polling has been stopped because
+	// of a fatal error (see Error.Fatal).
+	Stopped bool
+}
+
+// State returns the current health state of the poller.
+func (p *LongPoller) State() PollerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
 }
 
 // Poll does long polling
 func (p *LongPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	if p.Store != nil {
+		updateID, err := p.Store.Load()
+		if err != nil {
+			b.OnError(err, nil)
+		} else if updateID > p.LastUpdateID {
+			p.LastUpdateID = updateID
+		}
+
+		p.mu.Lock()
+		p.committed, p.saved = p.LastUpdateID, p.LastUpdateID
+		p.bot, p.polling = b, true
+		p.progress = make(chan struct{}, 1)
+		p.mu.Unlock()
+
+		defer func() {
+			p.mu.Lock()
+			p.polling = false
+			p.mu.Unlock()
+			p.save(b)
+		}()
+	}
+
+	allowed := p.AllowedUpdates
+	if p.AutoAllowedUpdates {
+		allowed = b.AllowedUpdates()
+	}
+
 	for {
 		select {
 		case <-stop:
@@ -35,15 +132,272 @@ func (p *LongPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 		default:
 		}
 
-		updates, err := b.getUpdates(p.LastUpdateID+1, p.Limit, p.Timeout)
+		p.save(b)
+
+		offset := p.LastUpdateID + 1
+		if p.Store != nil {
+			p.mu.Lock()
+			offset = p.committed + 1
+			p.mu.Unlock()
+		}
+
+		updates, err := b.getUpdates(offset, p.Limit, p.Timeout, allowed)
 		if err != nil {
+			failures := p.fail(err)
+
+			if IsFatal(err) {
+				b.OnError(err, nil)
+				return
+			}
 			b.debug(err)
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(p.backoff(err, failures)):
+			}
 			continue
 		}
 
+		p.succeed()
+
+		delivered := false
 		for _, update := range updates {
-			p.LastUpdateID = update.UpdateID
-			dest <- update
+			if update.UpdateID <= p.LastUpdateID {
+				continue
+			}
+
+			select {
+			case dest <- update:
+				p.LastUpdateID = update.UpdateID
+				delivered = true
+			case <-stop:
+				return
+			}
 		}
+
+		// Only the delivered but not yet committed updates came back,
+		// so Telegram would return them right away again. Wait until
+		// they are processed instead of spinning.
+		if len(updates) > 0 && !delivered {
+			select {
+			case <-stop:
+				return
+			case <-p.progress:
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+func (p *LongPoller) fail(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.ConsecutiveFailures++
+	p.state.LastError = err
+	p.state.Stopped = IsFatal(err)
+	return p.state.ConsecutiveFailures
+}
+
+func (p *LongPoller) succeed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.LastSuccess = time.Now()
+	p.state.ConsecutiveFailures = 0
+	p.state.LastError = nil
+}
+
+// backoff returns the delay before the next request after the given
+// number of consecutive failures. Half of the delay is randomized.
+func (p *LongPoller) backoff(err error, failures int) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second
+	}
+
+	minDelay, maxDelay := p.MinBackoff, p.MaxBackoff
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = time.Minute
+	}
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+
+	d := minDelay
+	for i := 1; i < failures && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Commit marks the update as handed over to ProcessUpdate.
+// The offset is persisted before the next getUpdates request,
+// or right away once polling is stopped (see Bot.Shutdown).
+func (p *LongPoller) Commit(updateID int) {
+	p.mu.Lock()
+	if updateID > p.committed {
+		p.committed = updateID
+
+		select {
+		case p.progress <- struct{}{}:
+		default:
+		}
+	}
+	b, polling := p.bot, p.polling
+	p.mu.Unlock()
+
+	if b != nil && !polling {
+		p.save(b)
+	}
+}
+
+func (p *LongPoller) save(b *Bot) {
+	if p.Store == nil {
+		return
+	}
+
+	p.mu.Lock()
+	committed, saved := p.committed, p.saved
+	p.mu.Unlock()
+
+	if committed == saved {
+		return
+	}
+
+	if err := p.Store.Save(committed); err != nil {
+		b.OnError(err, nil)
+		return
+	}
+
+	p.mu.Lock()
+	p.saved = committed
+	p.mu.Unlock()
+}
+
+// MiddlewarePoller is a special kind of poller that acts
+// like a filter for updates. It could be used for spam
+// handling, banning or whatever.
+//
+// For heavy middleware, use increased capacity.
+type MiddlewarePoller struct {
+	// Capacity of the channel between the wrapped poller and
+	// the filter, defaulted to 1.
+	Capacity int
+
+	Poller Poller
+
+	// Filter is called for every update before it reaches
+	// Bot.Updates. It may modify the update in place (e.g. fill
+	// Update.Meta) and drops the update by returning false.
+	Filter func(*Update) bool
+
+	mu        sync.Mutex
+	forwarded int
+	committed int
+	dropped   int
+}
+
+// NewMiddlewarePoller wait for it... constructs a new middleware poller.
+func NewMiddlewarePoller(original Poller, filter func(*Update) bool) *MiddlewarePoller {
+	return &MiddlewarePoller{
+		Poller: original,
+		Filter: filter,
+	}
+}
+
+// Poll sieves updates through middleware filter.
+func (p *MiddlewarePoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	capacity := 1
+	if p.Capacity > 1 {
+		capacity = p.Capacity
+	}
+
+	middle := make(chan Update, capacity)
+	stopPoller := make(chan struct{})
+	stopConfirm := make(chan struct{})
+
+	go func() {
+		p.Poller.Poll(b, middle, stopPoller)
+		close(stopConfirm)
+	}()
+
+	for {
+		select {
+		case <-stop:
+			close(stopPoller)
+			<-stopConfirm
+			return
+		case <-stopConfirm:
+			// The wrapped poller has given up on its own.
+			<-stop
+			return
+		case upd := <-middle:
+			if p.Filter != nil && !p.Filter(&upd) {
+				p.drop(upd.UpdateID)
+				continue
+			}
+
+			p.mu.Lock()
+			p.forwarded = upd.UpdateID
+			p.mu.Unlock()
+
+			select {
+			case dest <- upd:
+			case <-stop:
+				close(stopPoller)
+				<-stopConfirm
+				return
+			}
+		}
+	}
+}
+
+// Commit passes the committed update to the wrapped poller.
+//
+// Updates dropped by the filter are committed by the poller itself, as
+// soon as all the updates passed before them have been committed, so
+// the offset keeps moving even if no update passes the filter.
+func (p *MiddlewarePoller) Commit(updateID int) {
+	p.mu.Lock()
+	if updateID > p.committed {
+		p.committed = updateID
+	}
+	if p.committed >= p.forwarded && p.dropped > p.committed {
+		p.committed = p.dropped
+	}
+	updateID = p.committed
+	p.mu.Unlock()
+
+	p.commit(updateID)
+}
+
+// drop commits the update dropped by the filter right away, unless
+// the updates passed before it are still waiting to be committed.
+func (p *MiddlewarePoller) drop(updateID int) {
+	p.mu.Lock()
+	if p.committed < p.forwarded {
+		p.dropped = updateID
+		p.mu.Unlock()
+		return
+	}
+	p.committed = updateID
+	p.mu.Unlock()
+
+	p.commit(updateID)
+}
+
+func (p *MiddlewarePoller) commit(updateID int) {
+	if c, ok := p.Poller.(OffsetCommitter); ok {
+		c.Commit(updateID)
 	}
 }
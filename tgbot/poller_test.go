@@ -0,0 +1,118 @@
+package tgbot
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// textMessage returns a message update with the text,
+// sent by the user to their private chat with the bot.
+func textMessage(user int, text string) string {
+	return fmt.Sprintf(`{"message":{"message_id":1,"date":0,`+
+		`"from":{"id":%d,"first_name":"User"},`+
+		`"chat":{"id":%d,"type":"private"},"text":%q}}`, user, user, text)
+}
+
+func TestMiddlewarePollerDropped(t *testing.T) {
+	api := newFakeAPI(t)
+	for i := 0; i < 150; i++ {
+		api.push(textMessage(1, "old"))
+	}
+	api.push(textMessage(1, "new"))
+
+	store := &MemoryOffsetStore{}
+	poller := NewMiddlewarePoller(&LongPoller{Store: store}, func(u *Update) bool {
+		return u.Message.Text != "old"
+	})
+	b := newTestBot(t, api, Settings{Poller: poller, Synchronous: true})
+
+	var handled int32
+	b.Handle(OnText, func(c Context) error {
+		if c.Text() != "new" {
+			t.Errorf("unexpected update %q", c.Text())
+		}
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	go b.Start()
+	waitFor(t, func() bool { return atomic.LoadInt32(&handled) == 1 })
+	b.Stop()
+
+	if id, _ := store.Load(); id != 151 {
+		t.Errorf("expected offset 151, got %d", id)
+	}
+}
+
+// committer is a poller recording the committed updates.
+type committer struct {
+	commits []int
+}
+
+func (p *committer) Poll(b *Bot, updates chan Update, stop chan struct{}) {}
+
+func (p *committer) Commit(updateID int) {
+	p.commits = append(p.commits, updateID)
+}
+
+func TestMiddlewarePollerCommitOrder(t *testing.T) {
+	wrapped := &committer{}
+	p := NewMiddlewarePoller(wrapped, nil)
+
+	// Update 1 is passed, 2 and 3 are dropped while 1 is still
+	// being processed, so they may be committed only after it.
+	p.forwarded = 1
+	p.drop(2)
+	p.drop(3)
+	if len(wrapped.commits) != 0 {
+		t.Fatalf("expected no commits yet, got %v", wrapped.commits)
+	}
+
+	p.Commit(1)
+	p.drop(4)
+
+	want := []int{3, 4}
+	if len(wrapped.commits) != len(want) || wrapped.commits[0] != 3 || wrapped.commits[1] != 4 {
+		t.Errorf("expected commits %v, got %v", want, wrapped.commits)
+	}
+}
+
+func TestLongPollerStore(t *testing.T) {
+	api := newFakeAPI(t)
+	for i := 1; i <= 4; i++ {
+		api.push(textMessage(1, fmt.Sprint(i)))
+	}
+
+	store := &MemoryOffsetStore{}
+	store.Save(2)
+
+	b := newTestBot(t, api, Settings{
+		Poller:      &LongPoller{Store: store},
+		Synchronous: true,
+	})
+
+	var texts []string
+	b.Handle(OnText, func(c Context) error {
+		texts = append(texts, c.Text())
+		return nil
+	})
+
+	go b.Start()
+	waitFor(t, func() bool {
+		id, _ := store.Load()
+		return id == 4
+	})
+	b.Stop()
+
+	if strings.Join(texts, ",") != "3,4" {
+		t.Errorf("expected updates 3,4 to be handled once, got %v", texts)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.offsets[0] != 3 {
+		t.Errorf("expected polling to start at offset 3, got %d", api.offsets[0])
+	}
+}
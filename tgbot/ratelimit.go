@@ -0,0 +1,270 @@
+package tgbot
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit configures the anti-flood limiter, see Settings.RateLimit.
+//
+// Every user and, optionally, every chat has a token bucket holding up
+// to Burst tokens, one token is spent per user action and refilled every
+// Refill. Actions arriving to an empty bucket are dropped by ProcessUpdate
+// before they are dispatched, so no handler, goroutine or API call is
+// spent on them. Other updates, e.g. chat member changes or service
+// messages, are never limited.
+//
+// Example:
+//
+//	b, err := tgbot.NewBot(tgbot.Settings{
+//		...
+//		RateLimit: &tgbot.RateLimit{
+//			Burst:        5,
+//			Refill:       time.Second,
+//			CooldownText: "Too many requests, please slow down.",
+//			Exempt:       config.Admins,
+//		},
+//	})
+type RateLimit struct {
+	// Burst is the number of updates a user can send at once,
+	// defaulted to 5.
+	Burst int
+
+	// Refill is the time a single token is refilled in,
+	// defaulted to 1 second.
+	Refill time.Duration
+
+	// ChatBurst and ChatRefill limit the updates of a chat as a whole,
+	// in addition to its users. Zero ChatBurst disables the chat limit,
+	// ChatRefill is defaulted to Refill.
+	ChatBurst  int
+	ChatRefill time.Duration
+
+	// Exempt lists the users which are never limited, e.g. admins.
+	Exempt []int64
+
+	// CooldownText, if set, is sent once to a user when they are
+	// limited, as a callback notification for button presses or
+	// a message otherwise. It is sent again only after the user
+	// has been let through.
+	CooldownText string
+
+	// OnLimited, if set, is called for every dropped update,
+	// e.g. for metrics.
+	OnLimited func(Context)
+
+	// Updates lists the types of the limited user actions, see Update*
+	// constants. Defaulted to messages, callback and inline queries.
+	Updates []string
+}
+
+// bucket is a token bucket of a single user or chat.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	warned bool
+	album  string
+}
+
+// limiter keeps the token buckets of users and chats.
+type limiter struct {
+	RateLimit
+
+	mu        sync.Mutex
+	exempt    map[int64]bool
+	limited   map[string]bool
+	users     map[int64]*bucket
+	chats     map[int64]*bucket
+	lastSweep time.Time
+}
+
+// limiterSweep is how often the buckets which have been
+// refilled completely are removed.
+const limiterSweep = time.Minute
+
+func newLimiter(r RateLimit) *limiter {
+	if r.Burst <= 0 {
+		r.Burst = 5
+	}
+	if r.Refill <= 0 {
+		r.Refill = time.Second
+	}
+	if r.ChatRefill <= 0 {
+		r.ChatRefill = r.Refill
+	}
+	if r.Updates == nil {
+		r.Updates = []string{UpdateMessage, UpdateCallbackQuery, UpdateInlineQuery}
+	}
+
+	l := &limiter{
+		RateLimit: r,
+		exempt:    make(map[int64]bool, len(r.Exempt)),
+		limited:   make(map[string]bool, len(r.Updates)),
+		users:     make(map[int64]*bucket),
+		chats:     make(map[int64]*bucket),
+		lastSweep: time.Now(),
+	}
+	for _, id := range r.Exempt {
+		l.exempt[id] = true
+	}
+	for _, typ := range r.Updates {
+		l.limited[typ] = true
+	}
+	return l
+}
+
+// limits reports whether the update is a user action
+// which is subject to the limit.
+func (l *limiter) limits(u Update) bool {
+	if !l.limited[updateType(u)] {
+		return false
+	}
+
+	// Service messages report changes rather than actions.
+	if m := u.Message; m != nil {
+		return m.NewChatMembers == nil && m.LeftChatMember == nil &&
+			m.NewChatTitle == "" && m.NewChatPhoto == nil && !m.DeleteChatPhoto &&
+			!m.GroupChatCreated && !m.SuperGroupChatCreated && !m.ChannelChatCreated &&
+			m.MigrateToChatID == 0 && m.MigrateFromChatID == 0 && m.PinnedMessage == nil
+	}
+	return true
+}
+
+// allow spends a token of the sender and the chat of the update.
+// If the update is limited, warn reports whether the cooldown
+// reply should be sent. The items of an album spend one token.
+func (l *limiter) allow(c Context, album string) (ok, warn bool) {
+	var (
+		userID, chatID int64
+		hasUser        bool
+		hasChat        bool
+	)
+	if sender := c.Sender(); sender != nil && sender.User != nil {
+		userID, hasUser = int64(sender.ID), true
+		if l.exempt[userID] {
+			return true, false
+		}
+	}
+	if chat := c.Chat(); chat != nil && chat.Chat != nil && l.ChatBurst > 0 {
+		chatID, hasChat = chat.ID, true
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	var user, chat *bucket
+	if hasUser {
+		user = l.refill(l.users, userID, l.Burst, l.Refill, now)
+	}
+	if hasChat {
+		chat = l.refill(l.chats, chatID, l.ChatBurst, l.ChatRefill, now)
+	}
+
+	if user != nil && album != "" && user.album == album {
+		return true, false
+	}
+
+	if (user != nil && user.tokens < 1) || (chat != nil && chat.tokens < 1) {
+		// Only the user is warned, the other members
+		// of a flooded chat are just limited.
+		if user != nil && !user.warned {
+			user.warned = true
+			return false, true
+		}
+		return false, false
+	}
+
+	if user != nil {
+		user.tokens--
+		user.warned = false
+		user.album = album
+	}
+	if chat != nil {
+		chat.tokens--
+	}
+	return true, false
+}
+
+// refill returns the bucket of the key with the tokens
+// refilled up to the moment.
+func (l *limiter) refill(buckets map[int64]*bucket, key int64, burst int, every time.Duration, now time.Time) *bucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		buckets[key] = b
+		return b
+	}
+
+	b.tokens += float64(now.Sub(b.last)) / float64(every)
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	return b
+}
+
+// sweep removes the buckets which would be full by now,
+// as they are no different from the new ones.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterSweep {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.users {
+		if b.tokens+float64(now.Sub(b.last))/float64(l.Refill) >= float64(l.Burst) {
+			delete(l.users, key)
+		}
+	}
+	for key, b := range l.chats {
+		if b.tokens+float64(now.Sub(b.last))/float64(l.ChatRefill) >= float64(l.ChatBurst) {
+			delete(l.chats, key)
+		}
+	}
+}
+
+// limit reports whether the update is dropped by the limiter,
+// sending the cooldown reply if needed.
+func (b *Bot) limit(u Update) bool {
+	if b.limiter == nil || !b.limiter.limits(u) {
+		return false
+	}
+
+	c := b.NewContext(u)
+
+	var album string
+	if u.AdditionalUpdateParams != nil {
+		album = u.MediaGroupID
+	}
+
+	ok, warn := b.limiter.allow(c, album)
+	if ok {
+		return false
+	}
+
+	if b.limiter.OnLimited != nil {
+		b.limiter.OnLimited(c)
+	}
+	if warn && b.limiter.CooldownText != "" {
+		b.dispatchUpdate(u, func(u Update, c Context) {
+			b.runHandler(b.cooldown, c)
+		})
+	}
+	return true
+}
+
+// cooldown sends the cooldown reply of the limiter.
+func (b *Bot) cooldown(c Context) error {
+	text := b.limiter.CooldownText
+	if c.Callback() != nil {
+		return c.Respond(&CallbackResponse{Text: text})
+	}
+	if c.Message() == nil {
+		return nil
+	}
+	return c.Send(text)
+}
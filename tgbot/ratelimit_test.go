@@ -0,0 +1,70 @@
+package tgbot
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitInlineCallback(t *testing.T) {
+	api := newFakeAPI(t)
+	b := newTestBot(t, api, Settings{
+		Synchronous: true,
+		RateLimit: &RateLimit{
+			Burst:        1,
+			ChatBurst:    1,
+			CooldownText: "slow down",
+		},
+	})
+
+	var handled int32
+	b.Handle(OnCallback, func(c Context) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	u := decodeUpdate(t, inlineCallback)
+	b.ProcessUpdate(u)
+	b.ProcessUpdate(u)
+
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("expected 1 handled callback, got %d", n)
+	}
+	if n := api.called("answerCallbackQuery"); n != 1 {
+		t.Errorf("expected 1 cooldown reply, got %d", n)
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{
+		Synchronous: true,
+		RateLimit:   &RateLimit{Burst: 2, Refill: time.Hour, Exempt: []int64{2}},
+	})
+
+	var texts []string
+	b.Handle(OnText, func(c Context) error {
+		texts = append(texts, c.Text())
+		return nil
+	})
+	var joined int
+	b.Handle(OnUserJoined, func(c Context) error {
+		joined++
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		b.ProcessUpdate(decodeUpdate(t, textMessage(1, "user")))
+		b.ProcessUpdate(decodeUpdate(t, textMessage(2, "admin")))
+		b.ProcessUpdate(decodeUpdate(t, `{"message":{"message_id":1,"date":0,
+			"from":{"id":1,"first_name":"User"},"chat":{"id":-1,"type":"group"},
+			"new_chat_members":[{"id":1,"first_name":"User"}]}}`))
+	}
+
+	if got := strings.Join(texts, ","); got != "user,admin,user,admin,admin" {
+		t.Errorf("unexpected handled updates %s", got)
+	}
+	if joined != 3 {
+		t.Errorf("expected service messages not to be limited, got %d of 3", joined)
+	}
+}
@@ -0,0 +1,149 @@
+package tgbot
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// recordedUpdate is a single line of a recording file.
+type recordedUpdate struct {
+	Time   time.Time       `json:"time"`
+	Update json.RawMessage `json:"update"`
+}
+
+// RecordingPoller wraps another poller and appends every update
+// it receives to the file at Path as a JSON line, before passing
+// the update on. The file can be played back with ReplayPoller.
+type RecordingPoller struct {
+	Poller Poller
+	Path   string
+}
+
+// NewRecordingPoller returns a poller recording updates of original to path.
+func NewRecordingPoller(original Poller, path string) *RecordingPoller {
+	return &RecordingPoller{
+		Poller: original,
+		Path:   path,
+	}
+}
+
+// Poll records updates of the wrapped poller.
+func (p *RecordingPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	f, err := os.OpenFile(p.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		b.OnError(wrapError(err), nil)
+		return
+	}
+	defer f.Close()
+
+	recorder := &MiddlewarePoller{
+		Poller: p.Poller,
+		Filter: func(u *Update) bool {
+			if err := p.record(f, u); err != nil {
+				b.OnError(err, nil)
+			}
+			return true
+		},
+	}
+	recorder.Poll(b, dest, stop)
+}
+
+// Commit passes the committed update to the wrapped poller.
+func (p *RecordingPoller) Commit(updateID int) {
+	if c, ok := p.Poller.(OffsetCommitter); ok {
+		c.Commit(updateID)
+	}
+}
+
+func (p *RecordingPoller) record(f *os.File, u *Update) error {
+	data := u.Raw
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(u); err != nil {
+			return wrapError(err)
+		}
+	}
+
+	line, err := json.Marshal(recordedUpdate{Time: time.Now(), Update: data})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// ReplayPoller feeds the bot with updates recorded by RecordingPoller.
+// Once the whole file is played, it waits for stop.
+type ReplayPoller struct {
+	Path string
+
+	// Speed multiplies the original pace of updates, e.g. 2 replays
+	// them twice as fast. Zero replays them without delays.
+	Speed float64
+}
+
+// NewReplayPoller returns a poller replaying path with the given speed.
+func NewReplayPoller(path string, speed float64) *ReplayPoller {
+	return &ReplayPoller{
+		Path:  path,
+		Speed: speed,
+	}
+}
+
+// Poll replays recorded updates.
+func (p *ReplayPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	defer func() { <-stop }()
+
+	f, err := os.Open(p.Path)
+	if err != nil {
+		b.OnError(wrapError(err), nil)
+		return
+	}
+	defer f.Close()
+
+	var last time.Time
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var rec recordedUpdate
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			b.OnError(wrapError(err), nil)
+			continue
+		}
+
+		u, err := newUpdate(rec.Update)
+		if err != nil {
+			b.OnError(err, nil)
+			continue
+		}
+
+		if p.Speed > 0 && !last.IsZero() {
+			delay := time.Duration(float64(rec.Time.Sub(last)) / p.Speed)
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-stop:
+					return
+				}
+			}
+		}
+		last = rec.Time
+
+		select {
+		case dest <- u:
+		case <-stop:
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		b.OnError(wrapError(err), nil)
+	}
+}
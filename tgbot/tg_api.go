@@ -1,29 +1,166 @@
 package tgbot
 
 import (
+	"encoding/json"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"net/url"
+	"reflect"
+	"strconv"
 	"time"
 )
 
-func (b *Bot) getUpdates(offset, limit int, timeout time.Duration) ([]Update, error) {
-	u := tgbotapi.NewUpdate(offset)
-	u.Limit = limit
-	u.Timeout = int(timeout.Seconds())
+// request calls the API method and returns the raw result. Unsuccessful
+// responses are returned as *Error.
+func (b *Bot) request(method string, params url.Values) (json.RawMessage, error) {
+	return b.requestWith(b.api, method, params)
+}
 
-	apiUpdates, err := b.api.GetUpdates(u)
+func (b *Bot) requestWith(api *tgbotapi.BotAPI, method string, params url.Values) (json.RawMessage, error) {
+	resp, err := api.MakeRequest(method, params)
 	if err != nil {
+		if resp.ErrorCode != 0 {
+			apiErr := &Error{
+				Code:        resp.ErrorCode,
+				Description: resp.Description,
+			}
+			if resp.Parameters != nil {
+				apiErr.RetryAfter = resp.Parameters.RetryAfter
+				apiErr.MigrateTo = resp.Parameters.MigrateToChatID
+			}
+			return nil, apiErr
+		}
 		return nil, wrapError(err)
 	}
+	return resp.Result, nil
+}
+
+// Raw calls any Bot API method with the given parameters and returns
+// the raw result. Strings, numbers and booleans are passed as is, any
+// other value (keyboards, lists, etc.) is encoded to JSON. Unsuccessful
+// responses are returned as *Error.
+//
+// Example:
+//
+//	result, err := b.Raw("setChatTitle", map[string]any{
+//		"chat_id": chat.ID,
+//		"title":   "New title",
+//	})
+func (b *Bot) Raw(method string, params map[string]any) (json.RawMessage, error) {
+	v := url.Values{}
+	for key, param := range params {
+		value, err := rawParam(key, param)
+		if err != nil {
+			return nil, err
+		}
+		v.Set(key, value)
+	}
+	return b.request(method, v)
+}
+
+// Call calls the Bot API method like Raw and decodes its result into T.
+//
+// Example:
+//
+//	count, err := tgbot.Call[int](b, "getChatMemberCount", map[string]any{
+//		"chat_id": chat.ID,
+//	})
+func Call[T any](b *Bot, method string, params map[string]any) (T, error) {
+	var result T
+
+	data, err := b.Raw(method, params)
+	if err != nil {
+		return result, err
+	}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, wrapError(err)
+	}
+	return result, nil
+}
 
-	updates := make([]Update, len(apiUpdates))
+// rawParam formats the parameter by its kind, so that the named types
+// (e.g. time.Duration) are not formatted by their String methods.
+func rawParam(key string, param any) (string, error) {
+	switch v := reflect.ValueOf(param); v.Kind() {
+	case reflect.Invalid:
+		return "", fmt.Errorf("tgbot: raw parameter %s is nil", key)
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if v.IsNil() {
+			return "", fmt.Errorf("tgbot: raw parameter %s is nil", key)
+		}
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
 
-	for i, apiUpdate := range apiUpdates {
-		updates[i] = Update{Update: apiUpdate, AdditionalUpdateParams: &AdditionalUpdateParams{}}
+	data, err := json.Marshal(param)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return string(data), nil
+}
+
+func (b *Bot) getUpdates(offset, limit int, timeout time.Duration, allowed []string) ([]Update, error) {
+	v := url.Values{}
+	if offset != 0 {
+		v.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	if timeout > 0 {
+		v.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+	}
+	if allowed != nil {
+		data, _ := json.Marshal(allowed)
+		v.Set("allowed_updates", string(data))
+	}
+
+	data, err := b.requestWith(b.pollAPI(timeout), "getUpdates", v)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawUpdates []json.RawMessage
+	if err := json.Unmarshal(data, &rawUpdates); err != nil {
+		return nil, wrapError(err)
+	}
+
+	updates := make([]Update, len(rawUpdates))
+
+	for i, rawUpdate := range rawUpdates {
+		if updates[i], err = newUpdate(rawUpdate); err != nil {
+			return nil, err
+		}
 	}
 
 	return updates, nil
 }
 
+// pollAPI returns the API to long poll with. The timeout of the client,
+// if any, is counted from the moment Telegram stops waiting for updates,
+// so that long polling is not cut short by it.
+func (b *Bot) pollAPI(timeout time.Duration) *tgbotapi.BotAPI {
+	if timeout <= 0 || b.api.Client == nil || b.api.Client.Timeout == 0 {
+		return b.api
+	}
+
+	client := *b.api.Client
+	client.Timeout += timeout
+
+	api := *b.api
+	api.Client = &client
+	return &api
+}
+
 func (b *Bot) sendText(to Recipient, text string, opt *SendOptions) (*Message, error) {
 	msg := tgbotapi.NewMessage(int64(to.ChatID()), text)
 
@@ -33,3 +170,29 @@ func (b *Bot) sendText(to Recipient, text string, opt *SendOptions) (*Message, e
 
 	return &Message{Message: &resp}, err
 }
+
+func (b *Bot) setWebhook(params map[string]string, cert string) error {
+	if cert == "" {
+		v := url.Values{}
+		for key, value := range params {
+			v.Set(key, value)
+		}
+		_, err := b.request("setWebhook", v)
+		return err
+	}
+
+	if _, err := b.api.UploadFile("setWebhook", params, "certificate", cert); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+func (b *Bot) deleteWebhook(dropPending bool) error {
+	v := url.Values{}
+	if dropPending {
+		v.Set("drop_pending_updates", "true")
+	}
+
+	_, err := b.request("deleteWebhook", v)
+	return err
+}
@@ -45,6 +45,17 @@ const (
 	// upon switching as its ID will change.
 	OnMigration = "\amigration"
 
+	// OnAlbum happens once all the items of an album (a media
+	// group) are received, instead of firing OnPhoto, OnVideo,
+	// etc. for each of them. See Context.Album, Settings.AlbumDelay.
+	//
+	// It is fired by a timer rather than by ProcessUpdate. With
+	// Synchronous set, it still never runs in parallel with other
+	// handlers, but ProcessUpdate returns before it is fired. In the
+	// ordered dispatch modes, it is run by the worker of the chat,
+	// possibly after the handlers of updates sent after the album.
+	OnAlbum = "\aalbum"
+
 	OnMedia           = "\amedia"
 	OnCallback        = "\acallback"
 	OnQuery           = "\aquery"
@@ -62,6 +73,39 @@ const (
 	OnVideoChatEnded        = "\avideo_chat_ended"
 	OnVideoChatParticipants = "\avideo_chat_participants_invited"
 	OnVideoChatScheduled    = "\avideo_chat_scheduled"
+
+	// OnAny fires for every received update before it is routed
+	// to the specific endpoint, e.g. for logging or metrics.
+	// It does not affect the routing itself, and sees the command
+	// payload but not the matches of the pattern endpoints.
+	//
+	// Unless Synchronous or an ordered dispatch mode is set, the
+	// handlers run concurrently, so OnAny is not guaranteed to finish,
+	// or even to start, before the handler of the specific endpoint.
+	OnAny = "\aany"
+
+	// OnUnhandled fires for updates which no other endpoint
+	// has handled, e.g. to reply with "unknown command".
+	OnUnhandled = "\aunhandled"
+)
+
+// These are update types which can be listed in the
+// AllowedUpdates of pollers.
+const (
+	UpdateMessage            = "message"
+	UpdateEditedMessage      = "edited_message"
+	UpdateChannelPost        = "channel_post"
+	UpdateEditedChannelPost  = "edited_channel_post"
+	UpdateInlineQuery        = "inline_query"
+	UpdateChosenInlineResult = "chosen_inline_result"
+	UpdateCallbackQuery      = "callback_query"
+	UpdateShippingQuery      = "shipping_query"
+	UpdatePreCheckoutQuery   = "pre_checkout_query"
+	UpdatePoll               = "poll"
+	UpdatePollAnswer         = "poll_answer"
+	UpdateMyChatMember       = "my_chat_member"
+	UpdateChatMember         = "chat_member"
+	UpdateChatJoinRequest    = "chat_join_request"
 )
 
 // ChatAction is a client-side status indicating bot activity.
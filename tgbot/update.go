@@ -1,110 +1,300 @@
 package tgbot
 
 import (
+	"encoding/json"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"strings"
+	"sync/atomic"
 )
 
 type Update struct {
 	tgbotapi.Update
-	*AdditionalUpdateParams
+	*AdditionalUpdateParams `json:"-"`
+
+	// The update kinds below are not modelled by tgbotapi.Update.
+	Poll            *Poll             `json:"poll,omitempty"`
+	PollAnswer      *PollAnswer       `json:"poll_answer,omitempty"`
+	MyChatMember    *ChatMemberUpdate `json:"my_chat_member,omitempty"`
+	ChatMember      *ChatMemberUpdate `json:"chat_member,omitempty"`
+	ChatJoinRequest *ChatJoinRequest  `json:"chat_join_request,omitempty"`
+
+	// Raw holds the update exactly as it was received, including
+	// the fields neither of the models above knows about. It is nil
+	// for the updates constructed in code.
+	Raw json.RawMessage `json:"-"`
 }
 
 type AdditionalUpdateParams struct {
 	Payload string
+
+	// ViaBot is the bot through which the message was sent.
+	ViaBot *User
+
+	// MediaGroupID is the album the message belongs to.
+	MediaGroupID string
+
+	// Album holds all the items of the album for OnAlbum.
+	Album []*Message
+
+	// Matches holds the submatches of the regexp or prefix
+	// endpoint which matched the update, see Context.Matches.
+	Matches    []string
+	matchNames []string
+
+	// Meta holds arbitrary data attached to the update before
+	// it is dispatched, e.g. by a MiddlewarePoller.
+	Meta map[string]interface{}
+}
+
+// newUpdate decodes a single update as it is sent by Telegram.
+func newUpdate(data []byte) (Update, error) {
+	var extras struct {
+		Message *struct {
+			ViaBot       *tgbotapi.User `json:"via_bot"`
+			MediaGroupID string         `json:"media_group_id"`
+		} `json:"message"`
+
+		Poll            *Poll             `json:"poll"`
+		PollAnswer      *PollAnswer       `json:"poll_answer"`
+		MyChatMember    *ChatMemberUpdate `json:"my_chat_member"`
+		ChatMember      *ChatMemberUpdate `json:"chat_member"`
+		ChatJoinRequest *ChatJoinRequest  `json:"chat_join_request"`
+	}
+
+	u := Update{AdditionalUpdateParams: &AdditionalUpdateParams{}}
+	if err := json.Unmarshal(data, &u.Update); err != nil {
+		return Update{}, wrapError(err)
+	}
+	if err := json.Unmarshal(data, &extras); err != nil {
+		return Update{}, wrapError(err)
+	}
+
+	if extras.Message != nil {
+		if extras.Message.ViaBot != nil {
+			u.ViaBot = &User{extras.Message.ViaBot}
+		}
+		u.MediaGroupID = extras.Message.MediaGroupID
+	}
+
+	u.Poll = extras.Poll
+	u.PollAnswer = extras.PollAnswer
+	u.MyChatMember = extras.MyChatMember
+	u.ChatMember = extras.ChatMember
+	u.ChatJoinRequest = extras.ChatJoinRequest
+	u.Raw = data
+
+	return u, nil
 }
 
 // ProcessUpdate processes a single incoming update.
 // A started bot calls this function automatically.
+//
+// Updates exceeding Settings.RateLimit are dropped here.
+//
+// In the ordered dispatch modes, the whole update is routed on the
+// worker of its chat or user, so that the routing sees the conversation
+// state left by the handlers of the previous updates.
 func (b *Bot) ProcessUpdate(u Update) {
+	if b.limit(u) {
+		return
+	}
+	b.dispatchUpdate(u, b.processUpdate)
+}
+
+// dispatchUpdate runs process for the update, on the worker
+// of its chat or user in the ordered dispatch modes.
+func (b *Bot) dispatchUpdate(u Update, process func(Update, Context)) {
 	c := b.NewContext(u)
 
+	if !b.synchronous && b.dispatcher != nil && b.dispatcher.ordered() {
+		done := b.track()
+		b.dispatcher.dispatch(&task{
+			c:      c,
+			run:    func() { defer done(); process(u, c) },
+			cancel: done,
+		})
+		return
+	}
+
+	if b.synchronous {
+		// Albums are fired by timers, see bufferAlbum.
+		b.serial.Lock()
+		defer b.serial.Unlock()
+	}
+
+	process(u, c)
+}
+
+func (b *Bot) processUpdate(u Update, c Context) {
+	command, ignored := b.parseCommand(u)
+
+	// Routing writes the payload and the matches of the update,
+	// so OnAny, which may run concurrently, gets a copy of them.
+	b.handle(OnAny, b.NewContext(u.snapshot()))
+
+	// Malicious messages and commands addressed to other
+	// bots are not reported as unhandled either.
+	if ignored {
+		return
+	}
+
+	if !b.route(u, c, command) {
+		b.handle(OnUnhandled, c)
+	}
+}
+
+// parseCommand extracts the payload of the command sent in the update
+// and returns the command. The update is ignored if it is a malicious
+// message or a command addressed to another bot.
+func (b *Bot) parseCommand(u Update) (command string, ignored bool) {
+	m := u.Message
+	if m == nil || m.PinnedMessage != nil || m.Text == "" {
+		return "", false
+	}
+
+	// Filtering malicious messages
+	if m.Text[0] == '\a' {
+		return "", true
+	}
+
+	match := cmdRx.FindAllStringSubmatch(m.Text, -1)
+	if match == nil {
+		return "", false
+	}
+
+	// Syntax: "</command>@<bot> <payload>"
+	command, botName := match[0][1], match[0][3]
+	if botName != "" && !strings.EqualFold(b.Me.UserName, botName) {
+		return "", true
+	}
+
+	if u.AdditionalUpdateParams != nil {
+		u.Payload = match[0][5]
+	}
+	return command, false
+}
+
+// snapshot returns a copy of the update which does not share
+// the data written while it is routed.
+func (u Update) snapshot() Update {
+	if u.AdditionalUpdateParams != nil {
+		params := *u.AdditionalUpdateParams
+		u.AdditionalUpdateParams = &params
+	}
+	if u.CallbackQuery != nil {
+		callback := *u.CallbackQuery
+		u.CallbackQuery = &callback
+	}
+	return u
+}
+
+// route fires the handler matching the update and reports whether
+// there was one. The command is the one parsed by parseCommand.
+func (b *Bot) route(u Update, c Context, command string) bool {
 	if u.Message != nil {
 		m := &Message{Message: u.Message}
 
 		if m.PinnedMessage != nil {
-			b.handle(OnPinned, c)
-			return
+			return b.handle(OnPinned, c)
 		}
 
 		// Commands
 		if m.Text != "" {
-			// Filtering malicious messages
-			if m.Text[0] == '\a' {
-				return
-			}
-
-			match := cmdRx.FindAllStringSubmatch(m.Text, -1)
-			if match != nil {
-				// Syntax: "</command>@<bot> <payload>"
-				command, botName := match[0][1], match[0][3]
-
-				if botName != "" && !strings.EqualFold(b.Me.UserName, botName) {
-					return
-				}
-
-				m.Payload = match[0][5]
-				u.Payload = m.Payload
-				if b.handle(command, c) {
-					return
-				}
+			if command != "" && b.handle(command, c) {
+				return true
 			}
 
 			// 1:1 satisfaction
 			if b.handle(m.Text, c) {
-				return
+				return true
+			}
+
+			// A conversation in progress takes the text
+			// before the global patterns.
+			if b.handleState(OnText, c) {
+				return true
+			}
+			if b.handlePattern(m.Text, false, c) {
+				return true
 			}
 
-			b.handle(OnText, c)
-			return
+			return b.handle(OnText, c)
+		}
+
+		if u.AdditionalUpdateParams != nil && u.MediaGroupID != "" && b.handlesAlbums() {
+			b.bufferAlbum(u)
+			return true
 		}
 
 		if b.handleMedia(c) {
-			return
+			return true
 		}
 
 		if m.Contact != nil {
-			b.handle(OnContact, c)
-			return
+			return b.handle(OnContact, c)
 		}
 		if m.Location != nil {
-			b.handle(OnLocation, c)
-			return
+			return b.handle(OnLocation, c)
 		}
 		if m.Venue != nil {
-			b.handle(OnVenue, c)
-			return
+			return b.handle(OnVenue, c)
 		}
 		if m.Game != nil {
-			b.handle(OnGame, c)
-			return
+			return b.handle(OnGame, c)
 		}
 		if m.Invoice != nil {
-			b.handle(OnInvoice, c)
-			return
+			return b.handle(OnInvoice, c)
+		}
+
+		// Service messages
+		if m.GroupChatCreated {
+			return b.handle(OnGroupCreated, c) || b.handle(OnAddedToGroup, c)
+		}
+		if m.SuperGroupChatCreated {
+			return b.handle(OnSuperGroupCreated, c) || b.handle(OnAddedToGroup, c)
+		}
+		if m.ChannelChatCreated {
+			return b.handle(OnChannelCreated, c)
+		}
+		if m.NewChatMembers != nil {
+			if b.isMember(*m.NewChatMembers) && b.handle(OnAddedToGroup, c) {
+				return true
+			}
+			return b.handle(OnUserJoined, c)
+		}
+		if m.LeftChatMember != nil {
+			return b.handle(OnUserLeft, c)
+		}
+		if m.NewChatTitle != "" {
+			return b.handle(OnNewGroupTitle, c)
+		}
+		if m.NewChatPhoto != nil {
+			return b.handle(OnNewGroupPhoto, c)
+		}
+		if m.DeleteChatPhoto {
+			return b.handle(OnGroupPhotoDeleted, c)
+		}
+		if m.MigrateToChatID != 0 {
+			return b.handle(OnMigration, c)
 		}
 	}
 
 	if u.EditedMessage != nil {
-		b.handle(OnEdited, c)
-		return
+		return b.handle(OnEdited, c)
 	}
 
 	if u.ChannelPost != nil {
 		m := u.ChannelPost
 
 		if m.PinnedMessage != nil {
-			b.handle(OnPinned, c)
-			return
+			return b.handle(OnPinned, c)
 		}
 
-		b.handle(OnChannelPost, c)
-		return
+		return b.handle(OnChannelPost, c)
 	}
 
 	if u.EditedChannelPost != nil {
-		b.handle(OnEditedChannelPost, c)
-		return
+		return b.handle(OnEditedChannelPost, c)
 	}
 
 	if u.CallbackQuery != nil {
@@ -113,39 +303,201 @@ func (b *Bot) ProcessUpdate(u Update) {
 			match := cbackRx.FindAllStringSubmatch(data, -1)
 			if match != nil {
 				unique, payload := match[0][1], match[0][3]
-				if handler, ok := b.handlers["\f"+unique]; ok {
+				if handler, ok := b.handler("\f"+unique, c); ok {
 					callback.Unique = unique
 					callback.Data = payload
 					u.Payload = payload
 					b.runHandler(handler, c)
-					return
+					return true
 				}
 			}
 		}
 
-		b.handle(OnCallback, c)
-		return
+		if b.handleState(OnCallback, c) {
+			return true
+		}
+		if b.handlePattern(callback.Data, true, c) {
+			return true
+		}
+
+		return b.handle(OnCallback, c)
+	}
+
+	if u.InlineQuery != nil {
+		return b.handle(OnQuery, c)
+	}
+
+	if u.ChosenInlineResult != nil {
+		return b.handle(OnInlineResult, c)
 	}
 
 	if u.ShippingQuery != nil {
-		b.handle(OnShipping, c)
-		return
+		return b.handle(OnShipping, c)
 	}
 
 	if u.PreCheckoutQuery != nil {
-		b.handle(OnCheckout, c)
-		return
+		return b.handle(OnCheckout, c)
+	}
+
+	if u.Poll != nil {
+		return b.handle(OnPoll, c)
+	}
+
+	if u.PollAnswer != nil {
+		return b.handle(OnPollAnswer, c)
+	}
+
+	if u.MyChatMember != nil {
+		return b.handle(OnMyChatMember, c)
+	}
+
+	if u.ChatMember != nil {
+		return b.handle(OnChatMember, c)
+	}
+
+	if u.ChatJoinRequest != nil {
+		return b.handle(OnChatJoinRequest, c)
+	}
+
+	return false
+}
+
+// updateType returns the type of the update, see Update* constants.
+func updateType(u Update) string {
+	switch {
+	case u.Message != nil:
+		return UpdateMessage
+	case u.EditedMessage != nil:
+		return UpdateEditedMessage
+	case u.ChannelPost != nil:
+		return UpdateChannelPost
+	case u.EditedChannelPost != nil:
+		return UpdateEditedChannelPost
+	case u.CallbackQuery != nil:
+		return UpdateCallbackQuery
+	case u.InlineQuery != nil:
+		return UpdateInlineQuery
+	case u.ChosenInlineResult != nil:
+		return UpdateChosenInlineResult
+	case u.ShippingQuery != nil:
+		return UpdateShippingQuery
+	case u.PreCheckoutQuery != nil:
+		return UpdatePreCheckoutQuery
+	case u.Poll != nil:
+		return UpdatePoll
+	case u.PollAnswer != nil:
+		return UpdatePollAnswer
+	case u.MyChatMember != nil:
+		return UpdateMyChatMember
+	case u.ChatMember != nil:
+		return UpdateChatMember
+	case u.ChatJoinRequest != nil:
+		return UpdateChatJoinRequest
+	}
+	return ""
+}
+
+// updateTypes returns the update types the endpoint
+// can be fired by, see AllowedUpdates.
+func updateTypes(end string) []string {
+	switch end {
+	case OnEdited:
+		return []string{UpdateEditedMessage}
+	case OnChannelPost:
+		return []string{UpdateChannelPost}
+	case OnEditedChannelPost:
+		return []string{UpdateEditedChannelPost}
+	case OnPinned:
+		return []string{UpdateMessage, UpdateChannelPost}
+	case OnCallback:
+		return []string{UpdateCallbackQuery}
+	case OnQuery:
+		return []string{UpdateInlineQuery}
+	case OnInlineResult:
+		return []string{UpdateChosenInlineResult}
+	case OnShipping:
+		return []string{UpdateShippingQuery}
+	case OnCheckout:
+		return []string{UpdatePreCheckoutQuery}
+	case OnPoll:
+		return []string{UpdatePoll}
+	case OnPollAnswer:
+		return []string{UpdatePollAnswer}
+	case OnMyChatMember:
+		return []string{UpdateMyChatMember}
+	case OnChatMember:
+		return []string{UpdateChatMember}
+	case OnChatJoinRequest:
+		return []string{UpdateChatJoinRequest}
+	case OnAny, OnUnhandled:
+		// Fired by whatever updates the other endpoints let in.
+		return nil
 	}
+
+	if strings.HasPrefix(end, "\f") {
+		return []string{UpdateCallbackQuery}
+	}
+
+	// Commands, plain text and message events.
+	return []string{UpdateMessage}
 }
 
 func (b *Bot) handle(end string, c Context) bool {
-	if handler, ok := b.handlers[end]; ok {
+	if handler, ok := b.handler(end, c); ok {
+		b.runHandler(handler, c)
+		return true
+	}
+	return false
+}
+
+// handler returns the handler of the endpoint, preferring the one
+// registered for the current conversation state, then the filtered
+// ones (see When).
+func (b *Bot) handler(end string, c Context) (HandlerFunc, bool) {
+	if handler, ok := b.stateHandler(end, c); ok {
+		return handler, true
+	}
+
+	for _, h := range b.filtered[end] {
+		if passes(h.filters, c) {
+			return h.handler, true
+		}
+	}
+
+	handler, ok := b.handlers[end]
+	return handler, ok
+}
+
+// stateHandler returns the handler of the endpoint
+// registered for the current conversation state.
+func (b *Bot) stateHandler(end string, c Context) (HandlerFunc, bool) {
+	if len(b.stateHandlers) == 0 {
+		return nil, false
+	}
+	handler, ok := b.stateHandlers[c.State()][end]
+	return handler, ok
+}
+
+// handleState fires the handler of the endpoint registered
+// for the current conversation state, if there is one.
+func (b *Bot) handleState(end string, c Context) bool {
+	if handler, ok := b.stateHandler(end, c); ok {
 		b.runHandler(handler, c)
 		return true
 	}
 	return false
 }
 
+// isMember reports whether the bot itself is among the users.
+func (b *Bot) isMember(users []tgbotapi.User) bool {
+	for _, u := range users {
+		if u.ID == b.Me.ID {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Bot) handleMedia(c Context) bool {
 	var (
 		m     = c.Message()
@@ -181,14 +533,36 @@ func (b *Bot) handleMedia(c Context) bool {
 }
 
 func (b *Bot) runHandler(h HandlerFunc, c Context) {
+	done := b.track()
+
 	f := func() {
+		defer done()
+
 		if err := h(c); err != nil {
 			b.OnError(err, c)
 		}
 	}
-	if b.synchronous {
+	switch {
+	case b.synchronous:
 		f()
-	} else {
+	case b.dispatcher != nil && b.dispatcher.ordered():
+		// Already on the worker, see ProcessUpdate.
+		f()
+	case b.dispatcher != nil:
+		b.dispatcher.dispatch(&task{c: c, run: f, cancel: done})
+	default:
 		go f()
 	}
 }
+
+// track counts a running handler in, see Shutdown.
+// The returned function counts it out.
+func (b *Bot) track() (done func()) {
+	b.running.Add(1)
+	atomic.AddInt64(&b.inFlight, 1)
+
+	return func() {
+		atomic.AddInt64(&b.inFlight, -1)
+		b.running.Done()
+	}
+}
@@ -0,0 +1,58 @@
+package tgbot
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOnAnyConcurrent(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{})
+
+	var (
+		mu   sync.Mutex
+		data []string
+	)
+	record := func(c Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		data = append(data, c.Data(), c.Callback().Data)
+		return nil
+	}
+
+	b.Handle(OnAny, func(c Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		data = append(data, "any:"+c.Data())
+		if cb := c.Callback(); cb != nil {
+			data = append(data, "any:"+cb.Data)
+		}
+		return nil
+	})
+	b.Handle("/start", func(c Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		data = append(data, "start:"+c.Data())
+		return nil
+	})
+	b.Handle(&Btn{Unique: "vote"}, record)
+	b.Handle(CallbackPrefix("poll "), record)
+
+	b.ProcessUpdate(decodeUpdate(t, textMessage(1, "/start hello")))
+	b.ProcessUpdate(decodeUpdate(t, `{"callback_query":{"id":"1",
+		"from":{"id":1,"first_name":"User"},"chat_instance":"1","data":"\fvote|up"}}`))
+	b.ProcessUpdate(decodeUpdate(t, `{"callback_query":{"id":"2",
+		"from":{"id":1,"first_name":"User"},"chat_instance":"1","data":"poll yes"}}`))
+	b.running.Wait()
+
+	want := map[string]bool{
+		"any:hello": true, "start:hello": true,
+		"any:\fvote|up": true, "up": true,
+		"any:poll yes": true, "yes": true,
+	}
+	for _, d := range data {
+		delete(want, d)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing %v in %q", want, data)
+	}
+}
@@ -0,0 +1,251 @@
+package tgbot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// WebhookSecretHeader is the header Telegram fills with Webhook.SecretToken
+// on every request sent to the webhook.
+const WebhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookMaxBody is the size limit of a single update
+// accepted by the webhook.
+const webhookMaxBody = 1 << 20
+
+// WebhookTLS represents the local TLS certificate and key
+// used to serve the webhook listener over HTTPS.
+type WebhookTLS struct {
+	Key  string
+	Cert string
+}
+
+// WebhookEndpoint describes the public address of the webhook.
+// It is used when the bot is reachable under an address that
+// differs from the one it listens on (e.g. behind a load balancer).
+type WebhookEndpoint struct {
+	// PublicURL is the full URL Telegram will send updates to.
+	PublicURL string
+
+	// Cert is a path to the public certificate to upload,
+	// only required for self-signed certificates.
+	Cert string
+}
+
+// Webhook is a Poller that receives updates from Telegram
+// over HTTP(S) instead of polling for them.
+//
+// If Listen is set, Poll starts its own HTTP server on that address.
+// Otherwise no listener is started, and the Webhook, being an
+// http.Handler itself, is expected to be mounted into an existing
+// server by the caller:
+//
+//	wh := &tgbot.Webhook{Endpoint: &tgbot.WebhookEndpoint{PublicURL: url}}
+//	mux.Handle("/tg/updates", wh)
+type Webhook struct {
+	// Listen is the address to start the HTTP server on, e.g. ":8443".
+	Listen string
+
+	// MaxConnections is the maximum number of simultaneous
+	// connections Telegram opens to the webhook, 1-100.
+	MaxConnections int
+
+	// DropUpdates drops all pending updates on setWebhook
+	// and deleteWebhook calls.
+	DropUpdates bool
+
+	// AllowedUpdates lists the update types to receive, see Update*
+	// constants. Nil keeps the subscription of the previous webhook.
+	AllowedUpdates []string
+
+	// AutoAllowedUpdates derives AllowedUpdates from the handlers
+	// registered by the moment polling starts (see Bot.AllowedUpdates).
+	AutoAllowedUpdates bool
+
+	// SecretToken is sent by Telegram in every webhook request
+	// and checked against the X-Telegram-Bot-Api-Secret-Token header.
+	SecretToken string
+
+	// IgnoreSetWebhook skips the setWebhook call, which is useful
+	// when the webhook is registered by some other process.
+	IgnoreSetWebhook bool
+
+	// TLS enables HTTPS on the built-in listener.
+	TLS *WebhookTLS
+
+	// Endpoint is the public address of the webhook. Its PublicURL
+	// is required unless IgnoreSetWebhook is set, as the Listen
+	// address is not a URL Telegram could reach.
+	Endpoint *WebhookEndpoint
+
+	mu   sync.RWMutex
+	dest chan<- Update
+	stop chan struct{}
+	bot  *Bot
+}
+
+// Poll registers the webhook and starts feeding updates into dest
+// until stop is closed. The webhook is deleted on stop.
+func (h *Webhook) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	h.mu.Lock()
+	h.bot = b
+	h.dest = dest
+	h.stop = stop
+	h.mu.Unlock()
+
+	if !h.IgnoreSetWebhook {
+		// Updates must not be accepted by a mounted handler
+		// of a webhook which has not been registered.
+		if h.Endpoint == nil || h.Endpoint.PublicURL == "" {
+			h.detach()
+			b.OnError(ErrNoWebhookURL, nil)
+			return
+		}
+		if err := b.setWebhook(h.params(b), h.certificate()); err != nil {
+			h.detach()
+			b.OnError(err, nil)
+			return
+		}
+	}
+
+	if h.Listen == "" {
+		<-stop
+		h.shutdown(b)
+		return
+	}
+
+	s := &http.Server{
+		Addr:    h.Listen,
+		Handler: h,
+	}
+
+	go func() {
+		var err error
+		if h.TLS != nil {
+			err = s.ListenAndServeTLS(h.TLS.Cert, h.TLS.Key)
+		} else {
+			err = s.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			b.OnError(wrapError(err), nil)
+		}
+	}()
+
+	<-stop
+	if err := s.Shutdown(context.Background()); err != nil {
+		b.debug(wrapError(err))
+	}
+	h.shutdown(b)
+}
+
+// ServeHTTP decodes a single update sent by Telegram and passes
+// it to the bot. It responds with 503 until the poller is started,
+// or if the webhook could not be registered.
+func (h *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.SecretToken != "" {
+		token := r.Header.Get(WebhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.SecretToken)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	h.mu.RLock()
+	dest, stop, b := h.dest, h.stop, h.bot
+	h.mu.RUnlock()
+
+	if dest == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+	if err != nil {
+		b.debug(wrapError(err))
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	u, err := newUpdate(data)
+	if err != nil {
+		b.debug(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case dest <- u:
+	case <-stop:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+func (h *Webhook) params(b *Bot) map[string]string {
+	params := map[string]string{
+		"url": h.Endpoint.PublicURL,
+	}
+
+	if h.MaxConnections != 0 {
+		params["max_connections"] = strconv.Itoa(h.MaxConnections)
+	}
+	if h.DropUpdates {
+		params["drop_pending_updates"] = "true"
+	}
+	if h.SecretToken != "" {
+		params["secret_token"] = h.SecretToken
+	}
+
+	allowed := h.AllowedUpdates
+	if h.AutoAllowedUpdates {
+		allowed = b.AllowedUpdates()
+	}
+	if allowed != nil {
+		data, _ := json.Marshal(allowed)
+		params["allowed_updates"] = string(data)
+	}
+
+	return params
+}
+
+func (h *Webhook) certificate() string {
+	if h.Endpoint != nil {
+		return h.Endpoint.Cert
+	}
+	return ""
+}
+
+// detach makes ServeHTTP reject the updates.
+func (h *Webhook) detach() {
+	h.mu.Lock()
+	h.dest = nil
+	h.mu.Unlock()
+}
+
+func (h *Webhook) shutdown(b *Bot) {
+	h.detach()
+
+	if h.IgnoreSetWebhook {
+		return
+	}
+	if err := b.deleteWebhook(h.DropUpdates); err != nil {
+		b.debug(err)
+	}
+}
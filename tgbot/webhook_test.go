@@ -0,0 +1,60 @@
+package tgbot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookNotRegistered(t *testing.T) {
+	var errs []error
+	b := newTestBot(t, newFakeAPI(t), Settings{
+		OnError: func(err error, c Context) { errs = append(errs, err) },
+	})
+
+	h := &Webhook{}
+	h.Poll(b, b.Updates, make(chan struct{}))
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNoWebhookURL) {
+		t.Fatalf("expected ErrNoWebhookURL, got %v", errs)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":1}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+	if len(b.Updates) != 0 {
+		t.Error("expected the update to be rejected")
+	}
+}
+
+func TestWebhookBodyLimit(t *testing.T) {
+	b := newTestBot(t, newFakeAPI(t), Settings{})
+
+	h := &Webhook{IgnoreSetWebhook: true}
+	stop := make(chan struct{})
+	go h.Poll(b, b.Updates, stop)
+	defer close(stop)
+
+	waitFor(t, func() bool {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return h.dest != nil
+	})
+
+	body := `{"update_id":1,"message":{"text":"` + strings.Repeat("a", webhookMaxBody) + `"}}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":2}`)))
+	if w.Code != http.StatusOK || len(b.Updates) != 1 {
+		t.Errorf("expected the update to be accepted, got %d", w.Code)
+	}
+}